@@ -6,6 +6,7 @@ package govenmo
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 )
@@ -19,14 +20,37 @@ type Account struct {
 	ExpiresIn    int64   `json:"expires_in"`
 	TokenType    string  `json:"bearer"`
 	User         `json:"user"`
+
+	// APIRoot, if set, is the Venmo API URL for this account's requests,
+	// instead of the package-level APIRoot.
+	APIRoot string `json:"-"`
+	// HTTPClient, if set, makes this account's requests instead of the
+	// package-level HTTPClient.
+	HTTPClient *http.Client `json:"-"`
+}
+
+// apiRoot is the Venmo API URL for the account's requests.
+func (a *Account) apiRoot() string {
+	if a.APIRoot != "" {
+		return a.APIRoot
+	}
+	return apiRoot()
+}
+
+// httpClient makes the account's requests.
+func (a *Account) httpClient() *http.Client {
+	if a.HTTPClient != nil {
+		return a.HTTPClient
+	}
+	return HTTPClient
 }
 
 // Refresh retrieves account information, including balance and biographical info
 // from the Venmo api.
 func (a *Account) Refresh() error {
-	url := apiRoot() + "/me?access_token=" + a.AccessToken
+	url := a.apiRoot() + "/me?access_token=" + a.AccessToken
 	logger.Println("account refresh using URL:", url)
-	resp, err := http.Get(url)
+	resp, err := a.httpClient().Get(url)
 	if err != nil {
 		logger.Println("Could get response from Venmo:", err)
 		return err
@@ -51,6 +75,11 @@ func (a *Account) Refresh() error {
 
 	logger.Printf("Parsed response from GET /me: %+v\n", *parsedResponse)
 
+	if parsedResponse.Error.Message != "" {
+		logger.Println("Error from Venmo API when refreshing account:", parsedResponse.Error.Message)
+		return errors.New(parsedResponse.Error.Message)
+	}
+
 	a.User = parsedResponse.Data.User
 	a.Balance = parsedResponse.Data.Balance
 
@@ -1,14 +1,16 @@
 package govenmo
 
 import (
+	"net/http"
+	"path/filepath"
 	"testing"
+
+	"github.com/deet/govenmo/venmotest"
 )
 
 func TestAccountRefresh(t *testing.T) {
-	account := &Account{}
-	account.AccessToken = "faketoken"
+	account := testAccount(sandboxURL)
 
-	Environment = "local_sandbox"
 	EnableLogging(nil)
 
 	err := account.Refresh()
@@ -21,3 +23,47 @@ func TestAccountRefresh(t *testing.T) {
 		t.Error("Parsed user info is wrong")
 	}
 }
+
+func TestAccountRefreshFromCassette(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "me.json")
+
+	recording := venmotest.NewRecordingTransport(path, nil)
+	account := testAccount(sandboxURL)
+	account.HTTPClient = &http.Client{Transport: recording}
+	if err := account.Refresh(); err != nil {
+		t.Fatal("/me should not have errored:", err)
+	}
+	if err := recording.Save(); err != nil {
+		t.Fatal("Cassette should have been saved:", err)
+	}
+
+	replay, err := venmotest.NewReplayTransport(path)
+	if err != nil {
+		t.Fatal("Cassette should have loaded:", err)
+	}
+	replay.Strict = true
+	account = &Account{AccessToken: "othertoken", APIRoot: sandboxURL, HTTPClient: &http.Client{Transport: replay}}
+	if err := account.Refresh(); err != nil {
+		t.Fatal("/me should have been replayed:", err)
+	}
+	if account.Id != "123245678901232456789" || account.Balance != 1.23 || *account.Email != venmotest.Scrubbed {
+		t.Error("Replayed user info is wrong")
+	}
+}
+
+func TestAccountRefreshOverTLS(t *testing.T) {
+	t.Parallel()
+	server := venmotest.NewTLSServer(venmotest.TLSServerOptions{RequireClientCert: true})
+	defer server.Close()
+
+	account := testAccount(server.URL)
+	if err := account.Refresh(); err == nil {
+		t.Error("Default client should not trust the sandbox's CA")
+	}
+
+	account.HTTPClient = server.Client()
+	if err := account.Refresh(); err != nil || account.Id != venmotest.MeId {
+		t.Error("/me should work over TLS with a client certificate:", err)
+	}
+}
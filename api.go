@@ -1,7 +1,8 @@
 package govenmo
 
 type userGetResponse struct {
-	Data Account
+	Data  Account
+	Error Error
 }
 
 type paymentPostData struct {
@@ -31,6 +32,10 @@ type userFriendsResponse struct {
 }
 
 func apiRoot() string {
+	if APIRoot != "" {
+		return APIRoot
+	}
+
 	switch Environment {
 	case "local_sandbox":
 		return "http://localhost:4000"
@@ -0,0 +1,27 @@
+package govenmo
+
+import (
+	"os"
+	"testing"
+
+	"github.com/deet/govenmo/venmotest"
+)
+
+// sandboxURL is the URL of an in-process sandbox shared by tests that do
+// not start their own.
+var sandboxURL string
+
+// testAccount returns an Account with a fake token, talking to the sandbox at root.
+func testAccount(root string) *Account {
+	return &Account{AccessToken: "faketoken", APIRoot: root}
+}
+
+func TestMain(m *testing.M) {
+	server := venmotest.NewServer()
+	sandboxURL = server.URL
+
+	code := m.Run()
+
+	server.Close()
+	os.Exit(code)
+}
@@ -0,0 +1,100 @@
+package govenmo
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultBatchWorkers is how many requests a batch makes at once unless
+// BatchOptions says otherwise.
+const DefaultBatchWorkers = 4
+
+// BatchOptions configures batch operations such as GetPayments.
+type BatchOptions struct {
+	// Workers is how many requests are made at once. Zero or less uses
+	// DefaultBatchWorkers.
+	Workers int
+}
+
+// PaymentResult is the outcome of fetching one payment in a batch. Err is
+// set if the payment could not be fetched, in which case Payment holds only
+// what was known before.
+type PaymentResult struct {
+	Id      string
+	Payment Payment
+	Err     error
+}
+
+// runBatch calls do for each of n items, running up to workers at once, and
+// returns each item's error. Items not started when ctx is done get ctx's error.
+func runBatch(ctx context.Context, n int, workers int, do func(i int) error) []error {
+	if workers <= 0 {
+		workers = DefaultBatchWorkers
+	}
+	if workers > n {
+		workers = n
+	}
+
+	errs := make([]error, n)
+	items := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range items {
+				if err := ctx.Err(); err != nil {
+					errs[i] = err
+					continue
+				}
+				errs[i] = do(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		items <- i
+	}
+	close(items)
+	wg.Wait()
+	return errs
+}
+
+// RefreshPayments refreshes each payment in place, like RefreshPayment, with
+// up to opts.Workers requests at once. A failure only affects its own
+// payment. Results are in the order of payments.
+func RefreshPayments(ctx context.Context, client Client, payments []*Payment, opts BatchOptions) []PaymentResult {
+	results := make([]PaymentResult, len(payments))
+	errs := runBatch(ctx, len(payments), opts.Workers, func(i int) error {
+		return refreshPayment(ctx, client, payments[i])
+	})
+	for i, payment := range payments {
+		results[i].Err = errs[i]
+		if payment != nil {
+			results[i].Id = payment.Id
+			results[i].Payment = *payment
+		}
+	}
+	return results
+}
+
+// GetPayments fetches the payments with the given IDs, with up to
+// opts.Workers requests at once. Results are in the order of ids.
+func GetPayments(ctx context.Context, client Client, ids []string, opts BatchOptions) []PaymentResult {
+	payments := make([]*Payment, len(ids))
+	for i, id := range ids {
+		payments[i] = &Payment{Id: id}
+	}
+	return RefreshPayments(ctx, client, payments, opts)
+}
+
+// RefreshPayments refreshes several of the account's payments at once. See
+// the package-level RefreshPayments.
+func (a *Account) RefreshPayments(ctx context.Context, payments []*Payment, opts BatchOptions) []PaymentResult {
+	return RefreshPayments(ctx, a, payments, opts)
+}
+
+// GetPayments fetches several of the account's payments by ID at once. See
+// the package-level GetPayments.
+func (a *Account) GetPayments(ctx context.Context, ids []string, opts BatchOptions) []PaymentResult {
+	return GetPayments(ctx, a, ids, opts)
+}
@@ -0,0 +1,76 @@
+package govenmo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetPayments(t *testing.T) {
+	account := testAccount(sandboxURL)
+
+	results := account.GetPayments(context.Background(), []string{"1111111111111111111", "ddd"}, BatchOptions{})
+	if len(results) != 2 {
+		t.Fatal("There should be a result per ID:", results)
+	}
+	if results[0].Err != nil || results[0].Id != "1111111111111111111" || results[0].Payment.Note != "The Meatball Shop" {
+		t.Errorf("Known payment should have been fetched: %+v", results[0])
+	}
+	if results[1].Err == nil || results[1].Id != "ddd" {
+		t.Errorf("Unknown payment should have failed on its own: %+v", results[1])
+	}
+}
+
+func TestRefreshPaymentsWorkers(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	failure := errors.New("Resource not found.")
+	client := &stubClient{refreshPayment: func(payment *Payment) error {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		if payment.Id == "3" {
+			return failure
+		}
+		payment.Status = "settled"
+		return nil
+	}}
+
+	var payments []*Payment
+	for _, id := range []string{"1", "2", "3", "4", "5", "6"} {
+		payments = append(payments, &Payment{Id: id, Status: "pending"})
+	}
+	results := RefreshPayments(context.Background(), client, payments, BatchOptions{Workers: 2})
+
+	if maxRunning != 2 {
+		t.Error("Refresh should have run two requests at once, ran", maxRunning)
+	}
+	for i, result := range results {
+		switch {
+		case result.Id != payments[i].Id:
+			t.Error("Results should be in the order of payments:", i, result.Id)
+		case result.Id == "3" && (result.Err != failure || payments[i].Status != "pending"):
+			t.Errorf("Failed payment should keep its error and old state: %+v", result)
+		case result.Id != "3" && (result.Err != nil || payments[i].Status != "settled" || result.Payment.Status != "settled"):
+			t.Errorf("Payment should have been refreshed in place: %+v", result)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = RefreshPayments(ctx, client, payments, BatchOptions{})
+	if results[0].Err != context.Canceled {
+		t.Error("Refresh should not start once the context is done:", results[0].Err)
+	}
+}
@@ -0,0 +1,17 @@
+package govenmo
+
+import "time"
+
+// Client is the Venmo API as used through an Account. Depend on it instead of
+// *Account to unit test code without HTTP; the govenmotest package has an
+// in-memory Fake.
+type Client interface {
+	Refresh() error
+	PayOrCharge(target Target, amount float64, note string, audience string) (Payment, error)
+	CompletePayment(paymentId, action string) (Payment, error)
+	RefreshPayment(payment *Payment) error
+	PaymentsSince(updatedSince time.Time) ([]Payment, error)
+	FetchFriends() ([]User, error)
+}
+
+var _ Client = (*Account)(nil)
@@ -0,0 +1,121 @@
+package govenmo
+
+import (
+	"context"
+	"errors"
+)
+
+// Completion asks for a pending payment to be completed with an action:
+// "approve" or "deny" for a charge to the account, or "cancel" for one the
+// account made.
+type Completion struct {
+	PaymentId string
+	Action    string
+}
+
+// CompletionOutcome is what happened to one Completion in a batch.
+type CompletionOutcome string
+
+const (
+	// CompletionSucceeded means the payment was completed.
+	CompletionSucceeded CompletionOutcome = "succeeded"
+	// CompletionAlreadyCompleted means the payment was no longer pending,
+	// so nothing was sent.
+	CompletionAlreadyCompleted CompletionOutcome = "already_completed"
+	// CompletionFailed means the payment could not be fetched, the action
+	// does not apply to it, or Venmo refused it. See Err.
+	CompletionFailed CompletionOutcome = "failed"
+	// CompletionDryRun means the action is valid and would have been sent,
+	// had it not been a dry run.
+	CompletionDryRun CompletionOutcome = "dry_run"
+)
+
+// CompleteOptions configures CompletePayments.
+type CompleteOptions struct {
+	BatchOptions
+
+	// DryRun checks every completion against the payment's current state
+	// without completing anything.
+	DryRun bool
+}
+
+// CompletionResult reports one Completion. Payment is the payment as
+// completed, or as it was fetched if it was not completed.
+type CompletionResult struct {
+	Completion
+	Outcome CompletionOutcome
+	Payment Payment
+	Err     error
+}
+
+// checkCompletion returns why action cannot complete payment for the user
+// with ID userId, if it cannot.
+func checkCompletion(userId string, payment Payment, action string) error {
+	switch action {
+	case "approve", "deny":
+		if payment.Action != "charge" || payment.Target.User.Id != userId {
+			return errors.New("You can only " + action + " charges to you")
+		}
+	case "cancel":
+		if payment.Actor.Id != userId {
+			return errors.New("You can only cancel payments you made")
+		}
+	default:
+		return errors.New("Unknown action " + action)
+	}
+	return nil
+}
+
+// CompletePayments completes many payments for the user with ID userId, up
+// to opts.Workers at once. Each payment is fetched first, and only completed
+// if it is still pending and the action applies to it. Results are in the
+// order of completions.
+func CompletePayments(ctx context.Context, client Client, userId string, completions []Completion, opts CompleteOptions) []CompletionResult {
+	results := make([]CompletionResult, len(completions))
+	errs := runBatch(ctx, len(completions), opts.Workers, func(i int) (err error) {
+		result := &results[i]
+		result.Completion = completions[i]
+		result.Payment.Id = result.PaymentId
+
+		if userId == "" {
+			return errors.New("Completing payments needs the account's user ID, call Refresh first")
+		}
+		if err = refreshPayment(ctx, client, &result.Payment); err != nil {
+			return
+		}
+		if result.Payment.Status != "pending" {
+			result.Outcome = CompletionAlreadyCompleted
+			return
+		}
+		if err = checkCompletion(userId, result.Payment, result.Action); err != nil {
+			return
+		}
+		if opts.DryRun {
+			result.Outcome = CompletionDryRun
+			return
+		}
+
+		completed, err := client.CompletePayment(result.PaymentId, result.Action)
+		if err != nil {
+			logger.Println("Could not", result.Action, "payment", result.PaymentId, ":", err)
+			return
+		}
+		result.Outcome, result.Payment = CompletionSucceeded, completed
+		return
+	})
+
+	for i, err := range errs {
+		if err != nil {
+			results[i].Completion = completions[i]
+			results[i].Payment.Id = completions[i].PaymentId
+			results[i].Outcome, results[i].Err = CompletionFailed, err
+		}
+	}
+	return results
+}
+
+// CompletePayments completes many of the account's pending payments at
+// once. The account's ID must be known. See the package-level CompletePayments.
+func (a *Account) CompletePayments(ctx context.Context, completions []Completion, opts CompleteOptions) []CompletionResult {
+	return CompletePayments(ctx, a, a.Id, completions, opts)
+}
@@ -0,0 +1,113 @@
+package govenmo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// paymentsClient is a stubClient holding payments by ID, which completes
+// them the way Venmo would.
+func paymentsClient(payments map[string]Payment, completed *[]string) *stubClient {
+	var mu sync.Mutex
+	return &stubClient{
+		refreshPayment: func(payment *Payment) error {
+			mu.Lock()
+			defer mu.Unlock()
+			stored, ok := payments[payment.Id]
+			if !ok {
+				return errors.New("Resource not found.")
+			}
+			*payment = stored
+			return nil
+		},
+		completePayment: func(paymentId, action string) (Payment, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			*completed = append(*completed, paymentId+" "+action)
+			payment := payments[paymentId]
+			payment.Status = "settled"
+			if action != "approve" {
+				payment.Status = "cancelled"
+			}
+			payments[paymentId] = payment
+			return payment, nil
+		},
+	}
+}
+
+func TestCompletePayments(t *testing.T) {
+	me, friend := User{Id: "me"}, User{Id: "friend"}
+	payments := map[string]Payment{
+		"incoming": {Id: "incoming", Status: "pending", Action: "charge", Actor: friend, Target: Target{Type: "user", User: me}},
+		"outgoing": {Id: "outgoing", Status: "pending", Action: "charge", Actor: me, Target: Target{Type: "user", User: friend}},
+		"done":     {Id: "done", Status: "settled", Action: "charge", Actor: friend, Target: Target{Type: "user", User: me}},
+	}
+	completions := []Completion{
+		{"incoming", "approve"},
+		{"outgoing", "cancel"},
+		{"done", "deny"},
+		{"outgoing", "approve"},
+		{"missing", "deny"},
+	}
+
+	var completed []string
+	client := paymentsClient(payments, &completed)
+	results := CompletePayments(context.Background(), client, "me", completions, CompleteOptions{DryRun: true})
+	if len(completed) != 0 {
+		t.Error("Dry run should not have completed anything:", completed)
+	}
+	expected := []CompletionOutcome{CompletionDryRun, CompletionDryRun, CompletionAlreadyCompleted, CompletionFailed, CompletionFailed}
+	for i, result := range results {
+		if result.Outcome != expected[i] || result.Completion != completions[i] {
+			t.Errorf("Dry run of %v should have been %s: %+v", completions[i], expected[i], result)
+		}
+	}
+
+	results = CompletePayments(context.Background(), client, "me", completions, CompleteOptions{BatchOptions: BatchOptions{Workers: 1}})
+	if len(completed) != 2 {
+		t.Error("Only the valid completions should have been sent:", completed)
+	}
+	if results[0].Outcome != CompletionSucceeded || results[0].Payment.Status != "settled" {
+		t.Errorf("Approving an incoming charge should succeed: %+v", results[0])
+	}
+	if results[1].Outcome != CompletionSucceeded || results[1].Payment.Status != "cancelled" {
+		t.Errorf("Cancelling an outgoing charge should succeed: %+v", results[1])
+	}
+	if results[3].Outcome != CompletionAlreadyCompleted || results[3].Payment.Status != "cancelled" {
+		t.Errorf("Approving the just-cancelled charge should find it completed: %+v", results[3])
+	}
+	if results[4].Err == nil || results[4].Payment.Id != "missing" {
+		t.Errorf("Unknown payment should have failed: %+v", results[4])
+	}
+
+	results = CompletePayments(context.Background(), client, "", completions[:1], CompleteOptions{})
+	if results[0].Outcome != CompletionFailed || results[0].Err == nil {
+		t.Errorf("Completing without a user ID should fail: %+v", results[0])
+	}
+}
+
+func TestCompletePaymentsCancelsHungFetch(t *testing.T) {
+	t.Parallel()
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer hung.Close()
+
+	account := testAccount(hung.URL)
+	account.Id = "me"
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	results := account.CompletePayments(ctx, []Completion{{PaymentId: "1", Action: "approve"}}, CompleteOptions{})
+	if len(results) != 1 || results[0].Err == nil || results[0].Outcome != CompletionFailed {
+		t.Errorf("Completion should have failed with the deadline: %+v", results)
+	}
+	if waited := time.Since(started); waited > time.Second {
+		t.Error("Completion should have given up on the hung fetch at the deadline, waited", waited)
+	}
+}
@@ -0,0 +1,154 @@
+package govenmo
+
+import (
+	"sync"
+)
+
+// Event describes a change to a payment. It is one of PaymentCreated,
+// PaymentStatusChanged, PaymentAmountChanged or PaymentUpdated; use a type switch.
+type Event interface {
+	EventPayment() Payment
+}
+
+// PaymentCreated is emitted the first time a payment is seen.
+type PaymentCreated struct {
+	AccountId string
+	Payment   Payment
+}
+
+// PaymentStatusChanged is emitted when a payment's status changes, for example
+// from "pending" to "settled" or "cancelled".
+type PaymentStatusChanged struct {
+	AccountId string
+	Payment   Payment
+	From      string
+	To        string
+}
+
+// PaymentAmountChanged is emitted when a payment's amount changes.
+type PaymentAmountChanged struct {
+	AccountId string
+	Payment   Payment
+	From      float64
+	To        float64
+}
+
+// PaymentUpdated is emitted when a payment changed but the previous copy is
+// not known, for example for a webhook received without a PaymentStore.
+type PaymentUpdated struct {
+	AccountId string
+	Payment   Payment
+}
+
+func (e PaymentCreated) EventPayment() Payment       { return e.Payment }
+func (e PaymentStatusChanged) EventPayment() Payment { return e.Payment }
+func (e PaymentAmountChanged) EventPayment() Payment { return e.Payment }
+func (e PaymentUpdated) EventPayment() Payment       { return e.Payment }
+
+// PaymentEvents compares the previously known copy of a payment with the
+// current one. Pass a nil previous for a payment that has not been seen before.
+func PaymentEvents(accountId string, previous *Payment, current Payment) (events []Event) {
+	if previous == nil {
+		return []Event{PaymentCreated{AccountId: accountId, Payment: current}}
+	}
+	if previous.Status != current.Status {
+		events = append(events, PaymentStatusChanged{AccountId: accountId, Payment: current, From: previous.Status, To: current.Status})
+	}
+	if previous.Amount != current.Amount {
+		events = append(events, PaymentAmountChanged{AccountId: accountId, Payment: current, From: previous.Amount, To: current.Amount})
+	}
+	return
+}
+
+// EventBus delivers events to subscribers. The zero value is ready to use
+// and it is safe for concurrent use.
+type EventBus struct {
+	mu          sync.RWMutex
+	nextId      int
+	subscribers []subscriber
+}
+
+type subscriber struct {
+	id      int
+	handler func(Event)
+}
+
+// NewEventBus creates an EventBus with no subscribers.
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+func (b *EventBus) add(handler func(Event)) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextId++
+	b.subscribers = append(b.subscribers, subscriber{id: b.nextId, handler: handler})
+	return b.nextId
+}
+
+func (b *EventBus) remove(id int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, sub := range b.subscribers {
+		if sub.id == id {
+			b.subscribers = append(b.subscribers[:i:i], b.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Subscribe calls handler synchronously, on the publishing goroutine, for every
+// published event. A slow handler slows the publisher (e.g. a Syncer) down.
+// Call the returned function to unsubscribe.
+func (b *EventBus) Subscribe(handler func(Event)) (unsubscribe func()) {
+	id := b.add(handler)
+	return func() {
+		b.remove(id)
+	}
+}
+
+// SubscribeChan delivers events on a channel with the given buffer size.
+// Delivery never blocks the publisher: if the buffer is full the event is
+// dropped and logged. Unsubscribing closes the channel.
+func (b *EventBus) SubscribeChan(buffer int) (events <-chan Event, unsubscribe func()) {
+	ch := make(chan Event, buffer)
+	var mu sync.Mutex
+	closed := false
+
+	id := b.add(func(event Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case ch <- event:
+		default:
+			logger.Printf("Event channel full, dropping %T for payment %s\n", event, event.EventPayment().Id)
+		}
+	})
+
+	return ch, func() {
+		b.remove(id)
+		mu.Lock()
+		defer mu.Unlock()
+		if !closed {
+			closed = true
+			close(ch)
+		}
+	}
+}
+
+// Publish delivers events in order to every subscriber, in the order they
+// subscribed. Handlers may subscribe or unsubscribe while being called.
+func (b *EventBus) Publish(events ...Event) {
+	b.mu.RLock()
+	subscribers := b.subscribers
+	b.mu.RUnlock()
+
+	for _, event := range events {
+		for _, sub := range subscribers {
+			sub.handler(event)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package govenmo
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPaymentEvents(t *testing.T) {
+	current := Payment{Id: "1", Status: "pending", Amount: 5}
+
+	events := PaymentEvents("account", nil, current)
+	if created, ok := events[0].(PaymentCreated); len(events) != 1 || !ok || created.Payment.Id != "1" || created.AccountId != "account" {
+		t.Errorf("Expected a PaymentCreated: %+v", events)
+	}
+
+	previous := current
+	if events := PaymentEvents("account", &previous, current); len(events) != 0 {
+		t.Errorf("Unchanged payment should not produce events: %+v", events)
+	}
+
+	current.Status = "settled"
+	current.Amount = 6
+	events = PaymentEvents("account", &previous, current)
+	if len(events) != 2 {
+		t.Fatalf("Expected status and amount events: %+v", events)
+	}
+	if changed, ok := events[0].(PaymentStatusChanged); !ok || changed.From != "pending" || changed.To != "settled" {
+		t.Errorf("Expected a PaymentStatusChanged: %+v", events[0])
+	}
+	if changed, ok := events[1].(PaymentAmountChanged); !ok || changed.From != 5 || changed.To != 6 {
+		t.Errorf("Expected a PaymentAmountChanged: %+v", events[1])
+	}
+}
+
+func TestEventBus(t *testing.T) {
+	bus := NewEventBus()
+
+	var received []Event
+	unsubscribe := bus.Subscribe(func(event Event) {
+		received = append(received, event)
+	})
+	events, unsubscribeChan := bus.SubscribeChan(1)
+
+	bus.Publish(PaymentCreated{Payment: Payment{Id: "1"}})
+	// The channel buffer is full, so this is dropped for the channel subscriber.
+	bus.Publish(PaymentCreated{Payment: Payment{Id: "2"}})
+
+	unsubscribe()
+	bus.Publish(PaymentCreated{Payment: Payment{Id: "3"}})
+
+	if len(received) != 2 || received[1].EventPayment().Id != "2" {
+		t.Errorf("Synchronous subscriber got wrong events: %+v", received)
+	}
+
+	unsubscribeChan()
+	unsubscribeChan()
+	var fromChan []Event
+	for event := range events {
+		fromChan = append(fromChan, event)
+	}
+	if len(fromChan) != 1 || fromChan[0].EventPayment().Id != "1" {
+		t.Errorf("Channel subscriber got wrong events: %+v", fromChan)
+	}
+}
+
+func TestSyncPublishesEvents(t *testing.T) {
+	account := &Account{}
+	account.Id = "123245678901232456789"
+	fetched := []Payment{{Id: "1", Status: "pending"}}
+
+	syncer := NewSyncer(account, NewMemoryStore())
+	syncer.Events = NewEventBus()
+	syncer.fetch = func(since time.Time) ([]Payment, error) {
+		return fetched, nil
+	}
+
+	var received []Event
+	syncer.Events.Subscribe(func(event Event) {
+		received = append(received, event)
+	})
+
+	syncer.SyncOnce(context.Background())
+	fetched = []Payment{{Id: "1", Status: "cancelled"}}
+	syncer.SyncOnce(context.Background())
+
+	if len(received) != 2 {
+		t.Fatalf("Expected two events: %+v", received)
+	}
+	if _, ok := received[0].(PaymentCreated); !ok {
+		t.Errorf("Expected a PaymentCreated: %+v", received[0])
+	}
+	if changed, ok := received[1].(PaymentStatusChanged); !ok || changed.To != "cancelled" || changed.AccountId != account.Id {
+		t.Errorf("Expected a PaymentStatusChanged: %+v", received[1])
+	}
+}
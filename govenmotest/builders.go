@@ -0,0 +1,389 @@
+package govenmotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/deet/govenmo"
+	"github.com/deet/govenmo/venmotest"
+)
+
+// DefaultTime is when built payments are created unless At is called.
+var DefaultTime = time.Date(2014, 8, 18, 17, 15, 15, 0, time.UTC)
+
+// UserBuilder builds a govenmo.User.
+type UserBuilder struct {
+	user govenmo.User
+}
+
+// userId derives a Venmo-looking ID from a username, so the same username
+// always gets the same ID.
+func userId(username string) string {
+	return hashId([]byte(username))
+}
+
+// hashId derives a 19-digit Venmo-looking ID from data.
+func hashId(data []byte) string {
+	h := fnv.New64a()
+	h.Write(data)
+	return fmt.Sprintf("%019d", h.Sum64()%10000000000000000000)
+}
+
+// NewUser starts building a user. Its ID is derived from username and its
+// name from username's parts, so NewUser("jane-doe") is Jane Doe.
+func NewUser(username string) *UserBuilder {
+	names := strings.SplitN(username, "-", 2)
+	for i, name := range names {
+		if name != "" {
+			names[i] = strings.ToUpper(name[:1]) + name[1:]
+		}
+	}
+	if len(names) == 1 {
+		names = append(names, "User")
+	}
+	return &UserBuilder{user: govenmo.User{
+		Id:          userId(username),
+		Username:    username,
+		FirstName:   names[0],
+		LastName:    names[1],
+		DisplayName: names[0] + " " + names[1],
+		DateJoined:  govenmo.Time{Time: DefaultTime.AddDate(-1, 0, 0)},
+	}}
+}
+
+// ID sets the user's ID.
+func (b *UserBuilder) ID(id string) *UserBuilder {
+	b.user.Id = id
+	return b
+}
+
+// Name sets the user's first, last and display names.
+func (b *UserBuilder) Name(first, last string) *UserBuilder {
+	b.user.FirstName, b.user.LastName, b.user.DisplayName = first, last, first+" "+last
+	return b
+}
+
+// Email sets the user's email address.
+func (b *UserBuilder) Email(email string) *UserBuilder {
+	b.user.Email = &email
+	return b
+}
+
+// Phone sets the user's phone number.
+func (b *UserBuilder) Phone(phone string) *UserBuilder {
+	b.user.Phone = &phone
+	return b
+}
+
+// Friend sets whether the user is the account's friend.
+func (b *UserBuilder) Friend(isFriend bool) *UserBuilder {
+	b.user.IsFriend = &isFriend
+	return b
+}
+
+// Build returns the user.
+func (b *UserBuilder) Build() govenmo.User {
+	return b.user
+}
+
+// Sandbox returns the user in the form a venmotest sandbox takes.
+func (b *UserBuilder) Sandbox() venmotest.User {
+	user := venmotest.User{
+		Id:                b.user.Id,
+		Username:          b.user.Username,
+		DisplayName:       b.user.DisplayName,
+		FirstName:         b.user.FirstName,
+		LastName:          b.user.LastName,
+		About:             b.user.About,
+		ProfilePictureUrl: b.user.ProfilePictureUrl,
+		DateJoined:        b.user.DateJoined.UTC().Format(govenmo.VenmoTimeFormat),
+	}
+	if b.user.Email != nil {
+		user.Email = *b.user.Email
+	}
+	if b.user.Phone != nil {
+		user.Phone = *b.user.Phone
+	}
+	return user
+}
+
+// TargetBuilder builds a govenmo.Target.
+type TargetBuilder struct {
+	target govenmo.Target
+}
+
+// ToUser starts building a target that is a Venmo user.
+func ToUser(user govenmo.User) *TargetBuilder {
+	return &TargetBuilder{target: govenmo.Target{Type: "user", User: user}}
+}
+
+// ToEmail starts building a target that is an email address.
+func ToEmail(email string) *TargetBuilder {
+	return &TargetBuilder{target: govenmo.Target{Type: "email", Email: email}}
+}
+
+// ToPhone starts building a target that is a phone number.
+func ToPhone(phone string) *TargetBuilder {
+	return &TargetBuilder{target: govenmo.Target{Type: "phone", Phone: phone}}
+}
+
+// Build returns the target.
+func (b *TargetBuilder) Build() govenmo.Target {
+	return b.target
+}
+
+// PaymentBuilder builds a govenmo.Payment. Unless set otherwise, a payment
+// is a pending 1.00 public payment from "actor" to "target", created at
+// DefaultTime.
+type PaymentBuilder struct {
+	payment   govenmo.Payment
+	completed *time.Time
+}
+
+// Builders numbers the payments it builds. Create one per test, so that a
+// test's payment IDs depend only on what it builds, not on which tests ran
+// before it. A Builders is safe for concurrent use.
+type Builders struct {
+	lastPaymentId int64
+}
+
+// NewBuilders creates a Builders whose first payment ID is
+// 1000000000000000001.
+func NewBuilders() *Builders {
+	return &Builders{lastPaymentId: 1000000000000000000}
+}
+
+// NewPayment starts building a payment with the next ID.
+func (b *Builders) NewPayment() *PaymentBuilder {
+	return newPayment(strconv.FormatInt(atomic.AddInt64(&b.lastPaymentId, 1), 10))
+}
+
+// NewCharge starts building a charge with the next ID.
+func (b *Builders) NewCharge() *PaymentBuilder {
+	return b.NewPayment().Charge()
+}
+
+// NewPayment starts building a payment. Unless ID is called, its ID is
+// derived from its contents when it is built, so it doesn't depend on what
+// other tests built. Payments built alike get the same ID; use ID or a
+// Builders to tell them apart.
+func NewPayment() *PaymentBuilder {
+	return newPayment("")
+}
+
+// NewCharge starts building a charge. Its ID is chosen like NewPayment's.
+func NewCharge() *PaymentBuilder {
+	return NewPayment().Charge()
+}
+
+func newPayment(id string) *PaymentBuilder {
+	return &PaymentBuilder{payment: govenmo.Payment{
+		Id:       id,
+		Status:   "pending",
+		Action:   "pay",
+		Actor:    NewUser("actor").Build(),
+		Target:   ToUser(NewUser("target").Build()).Build(),
+		Amount:   1,
+		Audience: "public",
+		Medium:   "api",
+	}}
+}
+
+// ID sets the payment's ID.
+func (b *PaymentBuilder) ID(id string) *PaymentBuilder {
+	b.payment.Id = id
+	return b
+}
+
+// From sets who made the payment or charge.
+func (b *PaymentBuilder) From(user govenmo.User) *PaymentBuilder {
+	b.payment.Actor = user
+	return b
+}
+
+// To sends the payment or charge to a Venmo user.
+func (b *PaymentBuilder) To(user govenmo.User) *PaymentBuilder {
+	b.payment.Target = ToUser(user).Build()
+	return b
+}
+
+// ToTarget sends the payment or charge to any target.
+func (b *PaymentBuilder) ToTarget(target govenmo.Target) *PaymentBuilder {
+	b.payment.Target = target
+	return b
+}
+
+// Amount sets the amount from a decimal string such as "6.00". It panics if
+// amount is not a number.
+func (b *PaymentBuilder) Amount(amount string) *PaymentBuilder {
+	parsed, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		panic("govenmotest: invalid amount " + amount)
+	}
+	b.payment.Amount = parsed
+	return b
+}
+
+// Pay makes it a payment.
+func (b *PaymentBuilder) Pay() *PaymentBuilder {
+	b.payment.Action = "pay"
+	return b
+}
+
+// Charge makes it a charge.
+func (b *PaymentBuilder) Charge() *PaymentBuilder {
+	b.payment.Action = "charge"
+	return b
+}
+
+// Status sets the status.
+func (b *PaymentBuilder) Status(status string) *PaymentBuilder {
+	b.payment.Status = status
+	return b
+}
+
+// Pending marks it pending.
+func (b *PaymentBuilder) Pending() *PaymentBuilder { return b.Status("pending") }
+
+// Settled marks it settled.
+func (b *PaymentBuilder) Settled() *PaymentBuilder { return b.Status("settled") }
+
+// Cancelled marks it cancelled.
+func (b *PaymentBuilder) Cancelled() *PaymentBuilder { return b.Status("cancelled") }
+
+// Failed marks it failed.
+func (b *PaymentBuilder) Failed() *PaymentBuilder { return b.Status("failed") }
+
+// Note sets the note.
+func (b *PaymentBuilder) Note(note string) *PaymentBuilder {
+	b.payment.Note = note
+	return b
+}
+
+// Audience sets the audience: public, friends or private.
+func (b *PaymentBuilder) Audience(audience string) *PaymentBuilder {
+	b.payment.Audience = audience
+	return b
+}
+
+// At sets when the payment was created. Unless CompletedAt is called, a
+// payment that is not pending was also completed then.
+func (b *PaymentBuilder) At(t time.Time) *PaymentBuilder {
+	b.payment.DateCreated = &govenmo.Time{Time: t.UTC()}
+	return b
+}
+
+// CompletedAt sets when the payment was completed.
+func (b *PaymentBuilder) CompletedAt(t time.Time) *PaymentBuilder {
+	t = t.UTC()
+	b.completed = &t
+	return b
+}
+
+// Fee sets the fee.
+func (b *PaymentBuilder) Fee(fee float64) *PaymentBuilder {
+	b.payment.Fee = &fee
+	return b
+}
+
+// Refund sets the ID of the refund.
+func (b *PaymentBuilder) Refund(refundId string) *PaymentBuilder {
+	b.payment.Refund = &refundId
+	return b
+}
+
+// Build returns the payment.
+func (b *PaymentBuilder) Build() govenmo.Payment {
+	payment := b.payment
+	if payment.DateCreated == nil {
+		payment.DateCreated = &govenmo.Time{Time: DefaultTime}
+	}
+	switch {
+	case b.completed != nil:
+		payment.DateCompleted = &govenmo.Time{Time: *b.completed}
+	case payment.Status != "pending":
+		payment.DateCompleted = &govenmo.Time{Time: payment.DateCreated.Time}
+	}
+	if payment.Id == "" {
+		contents, err := json.Marshal(payment)
+		if err != nil {
+			panic("govenmotest: could not encode payment: " + err.Error())
+		}
+		payment.Id = hashId(contents)
+	}
+	return payment
+}
+
+type wireTarget struct {
+	Type  string        `json:"type"`
+	Email *string       `json:"email"`
+	Phone *string       `json:"phone"`
+	User  *govenmo.User `json:"user"`
+}
+
+type wirePayment struct {
+	Id            string        `json:"id"`
+	Status        string        `json:"status"`
+	Action        string        `json:"action"`
+	Actor         govenmo.User  `json:"actor"`
+	Target        wireTarget    `json:"target"`
+	Amount        float64       `json:"amount"`
+	Audience      string        `json:"audience"`
+	Note          string        `json:"note"`
+	Medium        string        `json:"medium"`
+	DateCreated   *govenmo.Time `json:"date_created"`
+	DateCompleted *govenmo.Time `json:"date_completed"`
+	Fee           *float64      `json:"fee"`
+	Refund        *string       `json:"refund"`
+}
+
+// JSON returns the payment in the Venmo API's wire format, as found in the
+// data of a GET /payments/{id} response or a webhook.
+func (b *PaymentBuilder) JSON() []byte {
+	payment := b.Build()
+	wire := wirePayment{
+		Id:            payment.Id,
+		Status:        payment.Status,
+		Action:        payment.Action,
+		Actor:         payment.Actor,
+		Amount:        payment.Amount,
+		Audience:      payment.Audience,
+		Note:          payment.Note,
+		Medium:        payment.Medium,
+		DateCreated:   payment.DateCreated,
+		DateCompleted: payment.DateCompleted,
+		Fee:           payment.Fee,
+		Refund:        payment.Refund,
+		Target:        wireTarget{Type: payment.Target.Type},
+	}
+	switch payment.Target.Type {
+	case "user":
+		user := payment.Target.User
+		wire.Target.User = &user
+	case "email":
+		wire.Target.Email = &payment.Target.Email
+	case "phone":
+		wire.Target.Phone = &payment.Target.Phone
+	}
+
+	b2, err := json.Marshal(wire)
+	if err != nil {
+		panic("govenmotest: could not encode payment: " + err.Error())
+	}
+	return b2
+}
+
+// Sandbox returns the payment in the form a venmotest sandbox takes, e.g.
+// for server.Sandbox.AddPayment.
+func (b *PaymentBuilder) Sandbox() venmotest.Payment {
+	var payment venmotest.Payment
+	if err := json.Unmarshal(b.JSON(), &payment); err != nil {
+		panic("govenmotest: could not convert payment: " + err.Error())
+	}
+	return payment
+}
@@ -0,0 +1,119 @@
+package govenmotest
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/deet/govenmo"
+	"github.com/deet/govenmo/venmotest"
+)
+
+func TestNewUser(t *testing.T) {
+	jane := NewUser("jane-doe").Email("jane@example.com").Friend(true).Build()
+	if jane.Username != "jane-doe" || jane.DisplayName != "Jane Doe" || jane.FirstName != "Jane" || jane.LastName != "Doe" {
+		t.Error("User should be named after its username:", jane)
+	}
+	if jane.Email == nil || *jane.Email != "jane@example.com" || jane.IsFriend == nil || !*jane.IsFriend {
+		t.Error("User should have the email and friendship set:", jane)
+	}
+	if len(jane.Id) != 19 || jane.Id != NewUser("jane-doe").Build().Id {
+		t.Error("User ID should be derived from the username:", jane.Id)
+	}
+	if jane.Id == NewUser("john-doe").Build().Id {
+		t.Error("Different usernames should get different IDs:", jane.Id)
+	}
+	if bob := NewUser("bob").Build(); bob.DisplayName != "Bob User" {
+		t.Error("Single-word username should get a last name:", bob.DisplayName)
+	}
+}
+
+func TestNewPayment(t *testing.T) {
+	builders := NewBuilders()
+	alice, bob := NewUser("alice").Build(), NewUser("bob").Build()
+	at := time.Date(2015, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	payment := builders.NewPayment().From(alice).To(bob).Amount("6.00").Note("Lunch").Settled().At(at).Build()
+	if payment.Actor.Id != alice.Id || payment.Target.Type != "user" || payment.Target.User.Id != bob.Id {
+		t.Error("Payment should be from alice to bob:", payment)
+	}
+	if payment.Amount != 6 || payment.Note != "Lunch" || payment.Status != "settled" || payment.Action != "pay" {
+		t.Error("Payment should have the amount, note and status set:", payment)
+	}
+	if !payment.DateCreated.Equal(at) || payment.DateCompleted == nil || !payment.DateCompleted.Equal(at) {
+		t.Error("Settled payment should have been created and completed at the given time:", payment.DateCreated, payment.DateCompleted)
+	}
+
+	charge := builders.NewCharge().ToTarget(ToEmail("someone@example.com").Build()).Build()
+	if charge.Action != "charge" || charge.Status != "pending" || charge.DateCompleted != nil || !charge.DateCreated.Equal(DefaultTime) {
+		t.Error("Charge should default to pending at DefaultTime:", charge)
+	}
+	if charge.Target.Type != "email" || charge.Target.Email != "someone@example.com" {
+		t.Error("Charge should be to the email address:", charge.Target)
+	}
+	if payment.Id != "1000000000000000001" || charge.Id != "1000000000000000002" {
+		t.Error("Payments should be numbered by their Builders:", payment.Id, charge.Id)
+	}
+	if again := NewBuilders().NewPayment().Build(); again.Id != payment.Id {
+		t.Error("Each Builders should start its own sequence:", again.Id)
+	}
+}
+
+func TestNewPaymentDerivesId(t *testing.T) {
+	lunch := NewPayment().From(NewUser("alice").Build()).Amount("6.00").Note("Lunch").Build()
+	if len(lunch.Id) != 19 || lunch.Id != NewPayment().From(NewUser("alice").Build()).Amount("6.00").Note("Lunch").Build().Id {
+		t.Error("Payment ID should be derived from the payment:", lunch.Id)
+	}
+	if dinner := NewCharge().From(NewUser("alice").Build()).Amount("6.00").Note("Dinner").Build(); dinner.Id == lunch.Id || dinner.Action != "charge" {
+		t.Error("Different payments should get different IDs:", dinner.Id)
+	}
+	if chosen := NewPayment().ID("42").Build(); chosen.Id != "42" {
+		t.Error("ID should override the derived ID:", chosen.Id)
+	}
+}
+
+func TestPaymentBuilderJSON(t *testing.T) {
+	builder := NewBuilders().NewPayment().From(NewUser("alice").Build()).To(NewUser("bob").Build()).Amount("6.00").Fee(0.25).Settled().
+		At(time.Date(2015, 3, 1, 12, 0, 0, 500000000, time.UTC))
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(builder.JSON(), &fields); err != nil {
+		t.Fatal("JSON should be valid:", err)
+	}
+	if fields["date_created"] != "2015-03-01T12:00:00.5" || fields["action"] != "pay" || fields["refund"] != nil {
+		t.Error("JSON should use the Venmo wire format:", fields)
+	}
+	if target := fields["target"].(map[string]interface{}); target["type"] != "user" || target["email"] != nil {
+		t.Error("JSON target should hold the user only:", target)
+	}
+
+	var parsed govenmo.Payment
+	if err := json.Unmarshal(builder.JSON(), &parsed); err != nil || !reflect.DeepEqual(parsed, builder.Build()) {
+		t.Errorf("JSON should parse back to the built payment: %+v %v", parsed, err)
+	}
+}
+
+func TestBuildersSeedSandbox(t *testing.T) {
+	t.Parallel()
+	server := venmotest.NewServer(venmotest.Stateful())
+	defer server.Close()
+
+	alice := NewUser("alice")
+	payment := NewBuilders().NewCharge().From(alice.Build()).To(NewUser("bob").Build()).Amount("12.50").Note("Tickets")
+	err := server.Sandbox.Seed(venmotest.Seed{
+		Users:    []venmotest.SeedUser{{User: alice.Sandbox()}},
+		Payments: []venmotest.Payment{payment.Sandbox()},
+	})
+	if err != nil {
+		t.Fatal("Seed should succeed:", err)
+	}
+
+	stored, ok := server.Sandbox.Payment(payment.Build().Id)
+	if !ok {
+		t.Fatal("Sandbox should hold the seeded payment")
+	}
+	if stored.Actor.Id != alice.Build().Id || stored.Action != "charge" || stored.Amount != 12.5 || stored.Note != "Tickets" || stored.Target.User == nil {
+		t.Errorf("Sandbox should hold the built payment: %+v", stored)
+	}
+}
@@ -0,0 +1,310 @@
+// Package govenmotest helps unit test code that uses govenmo, without HTTP.
+// Fake is an in-memory govenmo.Client. For tests that should exercise the
+// HTTP client too, use the venmotest sandbox instead.
+package govenmotest
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/deet/govenmo"
+)
+
+// Method names, for FailNext and CallsTo.
+const (
+	Refresh         = "Refresh"
+	PayOrCharge     = "PayOrCharge"
+	CompletePayment = "CompletePayment"
+	RefreshPayment  = "RefreshPayment"
+	PaymentsSince   = "PaymentsSince"
+	FetchFriends    = "FetchFriends"
+)
+
+// Call is a recorded call to a Fake. Args are the call's arguments in order.
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// Fake is an in-memory govenmo.Client. It keeps payments and friends, and
+// behaves like the Venmo API: payments to users with an ID settle at once,
+// other payments and all charges stay pending until completed, and
+// completing a payment that is not pending fails.
+//
+// Set one of the Func fields to program a method's response instead; it is
+// called with the fake unlocked. FailNext makes the next calls fail. Every
+// call is recorded. A Fake is safe for concurrent use.
+type Fake struct {
+	// Account is the account the fake acts as. Its User is the actor of
+	// payments made with PayOrCharge, and its Balance moves with them.
+	Account govenmo.Account
+
+	RefreshFunc         func() error
+	PayOrChargeFunc     func(target govenmo.Target, amount float64, note string, audience string) (govenmo.Payment, error)
+	CompletePaymentFunc func(paymentId, action string) (govenmo.Payment, error)
+	RefreshPaymentFunc  func(payment *govenmo.Payment) error
+	PaymentsSinceFunc   func(updatedSince time.Time) ([]govenmo.Payment, error)
+	FetchFriendsFunc    func() ([]govenmo.User, error)
+
+	mu       sync.Mutex
+	payments map[string]govenmo.Payment
+	order    []string
+	friends  []govenmo.User
+	failures map[string][]error
+	calls    []Call
+	nextId   int64
+	now      func() time.Time
+}
+
+var _ govenmo.Client = (*Fake)(nil)
+
+// NewFake creates a Fake acting as account.
+func NewFake(account govenmo.Account) *Fake {
+	return &Fake{
+		Account:  account,
+		payments: map[string]govenmo.Payment{},
+		failures: map[string][]error{},
+		nextId:   3000000000000000000,
+		now:      time.Now,
+	}
+}
+
+// FailNext makes the next call to method return err. Calling it several
+// times queues several failures.
+func (f *Fake) FailNext(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures[method] = append(f.failures[method], err)
+}
+
+// Calls returns every call made to the fake, oldest first.
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call{}, f.calls...)
+}
+
+// CallsTo returns the calls made to method.
+func (f *Fake) CallsTo(method string) (calls []Call) {
+	for _, call := range f.Calls() {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return
+}
+
+// AddPayment stores a payment, as if it had been made earlier. A missing Id
+// or DateCreated is filled in. It returns the stored payment.
+func (f *Fake) AddPayment(payment govenmo.Payment) govenmo.Payment {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.addPayment(payment)
+}
+
+// AddFriend adds a user to the account's friends.
+func (f *Fake) AddFriend(user govenmo.User) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.friends = append(f.friends, user)
+}
+
+// Payment returns a stored payment.
+func (f *Fake) Payment(id string) (govenmo.Payment, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	payment, ok := f.payments[id]
+	return payment, ok
+}
+
+func (f *Fake) addPayment(payment govenmo.Payment) govenmo.Payment {
+	if payment.Id == "" {
+		f.nextId++
+		payment.Id = strconv.FormatInt(f.nextId, 10)
+	}
+	if payment.DateCreated == nil {
+		payment.DateCreated = &govenmo.Time{Time: f.now().UTC()}
+	}
+	if _, ok := f.payments[payment.Id]; !ok {
+		f.order = append(f.order, payment.Id)
+	}
+	f.payments[payment.Id] = payment
+	return payment
+}
+
+// record notes a call and returns the failure queued for it, if any.
+func (f *Fake) record(method string, args ...interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, Call{Method: method, Args: args})
+	if queued := f.failures[method]; len(queued) > 0 {
+		f.failures[method] = queued[1:]
+		return queued[0]
+	}
+	return nil
+}
+
+func (f *Fake) Refresh() error {
+	if err := f.record(Refresh); err != nil {
+		return err
+	}
+	if f.RefreshFunc != nil {
+		return f.RefreshFunc()
+	}
+	return nil
+}
+
+func (f *Fake) PayOrCharge(target govenmo.Target, amount float64, note string, audience string) (govenmo.Payment, error) {
+	if err := f.record(PayOrCharge, target, amount, note, audience); err != nil {
+		return govenmo.Payment{}, err
+	}
+	if f.PayOrChargeFunc != nil {
+		return f.PayOrChargeFunc(target, amount, note, audience)
+	}
+
+	if amount == 0 || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return govenmo.Payment{}, errors.New("Invalid amount.")
+	}
+	switch {
+	case target.User.Id != "":
+		target.Type = "user"
+	case target.Email != "":
+		target.Type = "email"
+	case target.Phone != "":
+		target.Type = "phone"
+	default:
+		return govenmo.Payment{}, errors.New("You must specify a user_id, email or phone.")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	payment := govenmo.Payment{
+		Status:   "pending",
+		Action:   "pay",
+		Actor:    f.Account.User,
+		Target:   target,
+		Amount:   math.Abs(amount),
+		Audience: audience,
+		Note:     note,
+		Medium:   "api",
+	}
+	if amount < 0 {
+		payment.Action = "charge"
+	}
+	if payment.Action == "pay" && target.Type == "user" {
+		payment.Status = "settled"
+		payment.DateCompleted = &govenmo.Time{Time: f.now().UTC()}
+		f.Account.Balance -= payment.Amount
+	}
+	return f.addPayment(payment), nil
+}
+
+func (f *Fake) CompletePayment(paymentId, action string) (govenmo.Payment, error) {
+	if err := f.record(CompletePayment, paymentId, action); err != nil {
+		return govenmo.Payment{}, err
+	}
+	if f.CompletePaymentFunc != nil {
+		return f.CompletePaymentFunc(paymentId, action)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	payment, ok := f.payments[paymentId]
+	if !ok {
+		return govenmo.Payment{}, errors.New("Resource not found.")
+	}
+	if payment.Status != "pending" {
+		return govenmo.Payment{}, errors.New("This payment is not pending.")
+	}
+
+	incoming := payment.Action == "charge" && payment.Target.User.Id == f.Account.Id
+	switch {
+	case action == "approve" && incoming:
+		payment.Status = "settled"
+		f.Account.Balance -= payment.Amount
+	case action == "deny" && incoming:
+		payment.Status = "cancelled"
+	case action == "cancel" && payment.Actor.Id == f.Account.Id:
+		payment.Status = "cancelled"
+	default:
+		return govenmo.Payment{}, errors.New("You cannot " + action + " this payment.")
+	}
+	payment.DateCompleted = &govenmo.Time{Time: f.now().UTC()}
+	f.payments[paymentId] = payment
+	return payment, nil
+}
+
+func (f *Fake) RefreshPayment(payment *govenmo.Payment) error {
+	if payment == nil {
+		return errors.New("Cannot refresh nil payment")
+	}
+	if err := f.record(RefreshPayment, payment.Id); err != nil {
+		return err
+	}
+	if f.RefreshPaymentFunc != nil {
+		return f.RefreshPaymentFunc(payment)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stored, ok := f.payments[payment.Id]
+	if !ok {
+		return errors.New("Resource not found.")
+	}
+	*payment = stored
+	return nil
+}
+
+// updated is when a payment last changed, which PaymentsSince filters on.
+func updated(payment govenmo.Payment) time.Time {
+	var t time.Time
+	if payment.DateCreated != nil {
+		t = payment.DateCreated.Time
+	}
+	if payment.DateCompleted != nil && payment.DateCompleted.After(t) {
+		t = payment.DateCompleted.Time
+	}
+	return t
+}
+
+func (f *Fake) PaymentsSince(updatedSince time.Time) ([]govenmo.Payment, error) {
+	if err := f.record(PaymentsSince, updatedSince); err != nil {
+		return nil, err
+	}
+	if f.PaymentsSinceFunc != nil {
+		return f.PaymentsSinceFunc(updatedSince)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var payments []govenmo.Payment
+	for _, id := range f.order {
+		payment := f.payments[id]
+		if updated(payment).After(updatedSince) {
+			payments = append(payments, payment)
+		}
+	}
+	sort.SliceStable(payments, func(i, j int) bool {
+		return updated(payments[i]).After(updated(payments[j]))
+	})
+	return payments, nil
+}
+
+func (f *Fake) FetchFriends() ([]govenmo.User, error) {
+	if err := f.record(FetchFriends); err != nil {
+		return nil, err
+	}
+	if f.FetchFriendsFunc != nil {
+		return f.FetchFriendsFunc()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]govenmo.User{}, f.friends...), nil
+}
@@ -0,0 +1,118 @@
+package govenmotest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/deet/govenmo"
+)
+
+func newTestFake() *Fake {
+	account := govenmo.Account{Balance: 100}
+	account.Id = "me"
+	return NewFake(account)
+}
+
+// approveSmallCharges stands in for code that depends on govenmo.Client.
+func approveSmallCharges(client govenmo.Client, since time.Time) (approved int, err error) {
+	payments, err := client.PaymentsSince(since)
+	if err != nil {
+		return
+	}
+	for _, payment := range payments {
+		if payment.Action == "charge" && payment.Status == "pending" && payment.Amount < 10 {
+			if _, err = client.CompletePayment(payment.Id, "approve"); err != nil {
+				return
+			}
+			approved++
+		}
+	}
+	return
+}
+
+func TestFakePayOrCharge(t *testing.T) {
+	fake := newTestFake()
+
+	paid, err := fake.PayOrCharge(govenmo.Target{User: govenmo.User{Id: "friend"}}, 10, "Lunch", "private")
+	if err != nil || paid.Status != "settled" || paid.Action != "pay" || paid.Actor.Id != "me" || paid.Target.Type != "user" {
+		t.Errorf("Payment to a user should settle: %+v %v", paid, err)
+	}
+	if fake.Account.Balance != 90 {
+		t.Error("Balance should have moved:", fake.Account.Balance)
+	}
+
+	charged, err := fake.PayOrCharge(govenmo.Target{Phone: "15555555555"}, -5, "", "public")
+	if err != nil || charged.Status != "pending" || charged.Action != "charge" || charged.Amount != 5 || charged.Target.Type != "phone" {
+		t.Errorf("Charge should stay pending: %+v %v", charged, err)
+	}
+	if _, err := fake.PayOrCharge(govenmo.Target{}, 1, "", "public"); err == nil {
+		t.Error("Payment without a target should fail")
+	}
+
+	calls := fake.CallsTo(PayOrCharge)
+	if len(calls) != 3 || calls[0].Args[2] != "Lunch" || calls[0].Args[3] != "private" {
+		t.Errorf("Calls should have been recorded: %+v", calls)
+	}
+}
+
+func TestFakeWithCodeUnderTest(t *testing.T) {
+	fake := newTestFake()
+	me := govenmo.User{Id: "me"}
+	friend := govenmo.User{Id: "friend"}
+	small := fake.AddPayment(govenmo.Payment{Status: "pending", Action: "charge", Actor: friend, Target: govenmo.Target{Type: "user", User: me}, Amount: 4})
+	fake.AddPayment(govenmo.Payment{Status: "pending", Action: "charge", Actor: friend, Target: govenmo.Target{Type: "user", User: me}, Amount: 40})
+
+	approved, err := approveSmallCharges(fake, time.Time{})
+	if err != nil || approved != 1 {
+		t.Error("One charge should have been approved:", approved, err)
+	}
+	payment := govenmo.Payment{Id: small.Id}
+	if err := fake.RefreshPayment(&payment); err != nil || payment.Status != "settled" || fake.Account.Balance != 96 {
+		t.Error("Charge should have been settled:", payment.Status, err)
+	}
+	if _, err := fake.CompletePayment(small.Id, "deny"); err == nil {
+		t.Error("Settled charge should not be completed again")
+	}
+
+	fake.FailNext(PaymentsSince, errors.New("Rate limited"))
+	if _, err := approveSmallCharges(fake, time.Time{}); err == nil || err.Error() != "Rate limited" {
+		t.Error("Queued failure should have been returned:", err)
+	}
+	if _, err := approveSmallCharges(fake, time.Time{}); err != nil {
+		t.Error("Failure should only happen once:", err)
+	}
+}
+
+func TestFakeFuncs(t *testing.T) {
+	fake := newTestFake()
+	fake.AddFriend(govenmo.User{Id: "friend"})
+
+	friends, err := fake.FetchFriends()
+	if err != nil || len(friends) != 1 || friends[0].Id != "friend" {
+		t.Error("Seeded friends should be returned:", friends, err)
+	}
+
+	fake.FetchFriendsFunc = func() ([]govenmo.User, error) { return nil, errors.New("Boom") }
+	if _, err := fake.FetchFriends(); err == nil {
+		t.Error("Programmed response should have been used")
+	}
+	if len(fake.CallsTo(FetchFriends)) != 2 {
+		t.Error("Programmed calls should still be recorded")
+	}
+}
+
+func TestFakePaymentsSince(t *testing.T) {
+	fake := newTestFake()
+	base := time.Date(2014, 8, 18, 12, 0, 0, 0, time.UTC)
+	at := func(offset time.Duration) *govenmo.Time { return &govenmo.Time{Time: base.Add(offset)} }
+
+	fake.AddPayment(govenmo.Payment{Id: "old", DateCreated: at(-time.Hour)})
+	fake.AddPayment(govenmo.Payment{Id: "completed", DateCreated: at(-time.Hour), DateCompleted: at(2 * time.Hour)})
+	fake.AddPayment(govenmo.Payment{Id: "new", DateCreated: at(time.Hour)})
+
+	payments, _ := fake.PaymentsSince(base)
+	if len(payments) != 2 || payments[0].Id != "completed" || payments[1].Id != "new" {
+		t.Errorf("Payments updated since should be listed newest first: %+v", payments)
+	}
+}
@@ -0,0 +1,222 @@
+package govenmo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultJanitorMaxAge is how old a pending outgoing charge gets before a
+// Janitor cancels it.
+const DefaultJanitorMaxAge = 30 * 24 * time.Hour
+
+// DefaultJanitorInterval is how often Janitor.Run cleans up.
+const DefaultJanitorInterval = time.Hour
+
+// ReminderNotePrefix starts the note of a Janitor's reminder charge.
+const ReminderNotePrefix = "Reminder: "
+
+// JanitorAction reports what a Janitor did with one stale charge. Reminder
+// is the reminder charge, if one was sent, possibly by an earlier cleanup
+// whose cancel failed. Err is set if a step failed; a charge whose reminder
+// could not be sent is not cancelled.
+type JanitorAction struct {
+	Charge    Payment
+	Reminder  *Payment
+	Cancelled bool
+	Err       error
+}
+
+// JanitorReport reports what a single cleanup did. Pending is how many of
+// the account's outgoing charges were still pending.
+type JanitorReport struct {
+	Pending int
+	Stale   []JanitorAction
+}
+
+// Janitor cancels an account's outgoing charges that have been pending for
+// longer than MaxAge, optionally charging again first as a reminder.
+type Janitor struct {
+	Client Client
+	// UserId is the ID of the account's user, who made the charges.
+	UserId   string
+	MaxAge   time.Duration
+	Interval time.Duration
+
+	// Remind sends a new charge for the same amount to the same target
+	// before cancelling a stale one, with ReminderNotePrefix before its note.
+	// Each charge is reminded about once: reminders themselves are cancelled
+	// without another reminder, and a charge whose cancel failed is not
+	// reminded about again when it is retried by the same Janitor.
+	Remind bool
+
+	// DryRun reports the stale charges without reminding or cancelling.
+	DryRun bool
+
+	// OnCleanup, if set, is called by Run after every cleanup.
+	OnCleanup func(report JanitorReport, err error)
+
+	now func() time.Time
+
+	mu       sync.Mutex
+	reminded map[string]Payment
+}
+
+// NewJanitor creates a Janitor with the default maximum age and interval,
+// for an account that has been refreshed so that its ID is known.
+func NewJanitor(account *Account) *Janitor {
+	return &Janitor{
+		Client:   account,
+		UserId:   account.Id,
+		MaxAge:   DefaultJanitorMaxAge,
+		Interval: DefaultJanitorInterval,
+	}
+}
+
+// IsReminder reports whether payment is a reminder charge sent by a Janitor.
+func IsReminder(payment Payment) bool {
+	return strings.HasPrefix(payment.Note, ReminderNotePrefix)
+}
+
+// remind sends a reminder for charge, unless one was already sent.
+func (j *Janitor) remind(charge Payment) (*Payment, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if reminder, ok := j.reminded[charge.Id]; ok {
+		return &reminder, nil
+	}
+
+	reminder, err := j.Client.PayOrCharge(charge.Target, -charge.Amount, ReminderNotePrefix+charge.Note, charge.Audience)
+	if err != nil {
+		return nil, err
+	}
+	if j.reminded == nil {
+		j.reminded = map[string]Payment{}
+	}
+	j.reminded[charge.Id] = reminder
+	return &reminder, nil
+}
+
+// forgetReminders forgets the reminders sent for charges that are no longer
+// stale and pending, such as those cancelled by someone else.
+func (j *Janitor) forgetReminders(stale []Payment) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	keep := map[string]bool{}
+	for _, payment := range stale {
+		keep[payment.Id] = true
+	}
+	for id := range j.reminded {
+		if !keep[id] {
+			delete(j.reminded, id)
+		}
+	}
+}
+
+// clean reminds about and cancels one stale charge.
+func (j *Janitor) clean(charge Payment) (action JanitorAction) {
+	action.Charge = charge
+	if j.Remind && !IsReminder(charge) {
+		reminder, err := j.remind(charge)
+		if err != nil {
+			logger.Println("Could not send reminder for charge", charge.Id, ":", err)
+			action.Err = err
+			return
+		}
+		action.Reminder = reminder
+	}
+
+	cancelled, err := j.Client.CompletePayment(charge.Id, "cancel")
+	if err != nil {
+		logger.Println("Could not cancel stale charge", charge.Id, ":", err)
+		action.Err = err
+		return
+	}
+	action.Charge, action.Cancelled = cancelled, true
+
+	j.mu.Lock()
+	delete(j.reminded, charge.Id)
+	j.mu.Unlock()
+	return
+}
+
+// CleanOnce cancels the stale charges found by a single scan of the
+// account's payments. A failure on one charge is reported and does not stop
+// the others.
+func (j *Janitor) CleanOnce(ctx context.Context) (report JanitorReport, err error) {
+	if j.UserId == "" {
+		err = errors.New("Janitor needs the account's user ID, call Refresh first")
+		return
+	}
+
+	now := time.Now
+	if j.now != nil {
+		now = j.now
+	}
+	maxAge := j.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultJanitorMaxAge
+	}
+
+	payments, err := j.Client.PaymentsSince(time.Time{})
+	if err != nil {
+		logger.Println("Could not fetch payments to clean up:", err)
+		return
+	}
+
+	cutoff := now().Add(-maxAge)
+	var stale []Payment
+	for _, payment := range payments {
+		if payment.Status != "pending" || payment.Action != "charge" || payment.Actor.Id != j.UserId {
+			continue
+		}
+		report.Pending++
+		if payment.DateCreated != nil && payment.DateCreated.Before(cutoff) {
+			stale = append(stale, payment)
+		}
+	}
+	j.forgetReminders(stale)
+
+	for _, payment := range stale {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if j.DryRun {
+			report.Stale = append(report.Stale, JanitorAction{Charge: payment})
+			continue
+		}
+		report.Stale = append(report.Stale, j.clean(payment))
+	}
+
+	logger.Println("Janitor found", len(report.Stale), "stale of", report.Pending, "pending charges")
+	return
+}
+
+// Run cleans up immediately and then every Interval until ctx is cancelled,
+// which it returns. Cleanup errors are passed to OnCleanup and do not stop Run.
+func (j *Janitor) Run(ctx context.Context) error {
+	interval := j.Interval
+	if interval <= 0 {
+		interval = DefaultJanitorInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := j.CleanOnce(ctx)
+		if err != nil {
+			logger.Println("Charge cleanup failed:", err)
+		}
+		if j.OnCleanup != nil && ctx.Err() == nil {
+			j.OnCleanup(report, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
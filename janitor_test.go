@@ -0,0 +1,149 @@
+package govenmo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJanitorCleanOnce(t *testing.T) {
+	now := time.Date(2014, 8, 18, 17, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) *Time { return &Time{Time: now.Add(-d)} }
+	me, friend, other := User{Id: "me"}, User{Id: "friend"}, User{Id: "other"}
+
+	payments := []Payment{
+		{Id: "stale", Status: "pending", Action: "charge", Actor: me, Target: Target{Type: "user", User: friend}, Amount: 20, Note: "Dinner", Audience: "private", DateCreated: ago(40 * 24 * time.Hour)},
+		{Id: "stuck", Status: "pending", Action: "charge", Actor: me, Target: Target{Type: "user", User: other}, Amount: 5, DateCreated: ago(40 * 24 * time.Hour)},
+		{Id: "fresh", Status: "pending", Action: "charge", Actor: me, Target: Target{Type: "user", User: friend}, Amount: 5, DateCreated: ago(time.Hour)},
+		{Id: "incoming", Status: "pending", Action: "charge", Actor: friend, Target: Target{Type: "user", User: me}, Amount: 5, DateCreated: ago(40 * 24 * time.Hour)},
+		{Id: "paid", Status: "settled", Action: "charge", Actor: me, Target: Target{Type: "user", User: friend}, Amount: 5, DateCreated: ago(40 * 24 * time.Hour)},
+	}
+
+	var reminders []string
+	var cancelled []string
+	client := &stubClient{
+		paymentsSince: func(updatedSince time.Time) ([]Payment, error) { return payments, nil },
+		payOrCharge: func(target Target, amount float64, note string, audience string) (Payment, error) {
+			if target.User.Id == "other" {
+				return Payment{}, errors.New("Could not charge.")
+			}
+			reminders = append(reminders, note)
+			if amount != -20 || audience != "private" {
+				t.Error("Reminder should charge the same amount to the same audience:", amount, audience)
+			}
+			return Payment{Id: "reminder", Status: "pending", Action: "charge", Note: note}, nil
+		},
+		completePayment: func(paymentId, action string) (Payment, error) {
+			cancelled = append(cancelled, paymentId+" "+action)
+			return Payment{Id: paymentId, Status: "cancelled"}, nil
+		},
+	}
+
+	janitor := &Janitor{Client: client, UserId: "me", MaxAge: 30 * 24 * time.Hour, Remind: true, DryRun: true, now: func() time.Time { return now }}
+	report, err := janitor.CleanOnce(context.Background())
+	if err != nil || report.Pending != 3 || len(report.Stale) != 2 {
+		t.Fatalf("Dry run should have found two stale of three pending charges: %+v %v", report, err)
+	}
+	if len(reminders) != 0 || len(cancelled) != 0 {
+		t.Error("Dry run should not have reminded or cancelled:", reminders, cancelled)
+	}
+
+	janitor.DryRun = false
+	report, err = janitor.CleanOnce(context.Background())
+	if err != nil || len(report.Stale) != 2 {
+		t.Fatalf("Cleanup should have handled both stale charges: %+v %v", report, err)
+	}
+	if len(reminders) != 1 || reminders[0] != "Reminder: Dinner" || len(cancelled) != 1 || cancelled[0] != "stale cancel" {
+		t.Error("Cleanup should have reminded about and cancelled the stale charge:", reminders, cancelled)
+	}
+	if stale := report.Stale[0]; !stale.Cancelled || stale.Reminder == nil || stale.Charge.Status != "cancelled" || stale.Err != nil {
+		t.Errorf("Report should show the charge reminded and cancelled: %+v", stale)
+	}
+	if stuck := report.Stale[1]; stuck.Cancelled || stuck.Err == nil {
+		t.Errorf("Charge whose reminder failed should not be cancelled: %+v", stuck)
+	}
+}
+
+func TestJanitorRemindsOnce(t *testing.T) {
+	now := time.Date(2014, 8, 18, 17, 0, 0, 0, time.UTC)
+	old := &Time{Time: now.Add(-40 * 24 * time.Hour)}
+	me, friend := User{Id: "me"}, User{Id: "friend"}
+	toFriend := Target{Type: "user", User: friend}
+
+	payments := []Payment{
+		{Id: "stale", Status: "pending", Action: "charge", Actor: me, Target: toFriend, Amount: 20, Note: "Dinner", DateCreated: old},
+		{Id: "reminder", Status: "pending", Action: "charge", Actor: me, Target: toFriend, Amount: 5, Note: ReminderNotePrefix + "Lunch", DateCreated: old},
+	}
+
+	var reminders, cancelled []string
+	failCancel := true
+	client := &stubClient{
+		paymentsSince: func(updatedSince time.Time) ([]Payment, error) { return payments, nil },
+		payOrCharge: func(target Target, amount float64, note string, audience string) (Payment, error) {
+			reminders = append(reminders, note)
+			return Payment{Id: "new", Status: "pending", Action: "charge", Note: note}, nil
+		},
+		completePayment: func(paymentId, action string) (Payment, error) {
+			if paymentId == "stale" && failCancel {
+				return Payment{}, errors.New("Service unavailable.")
+			}
+			cancelled = append(cancelled, paymentId)
+			return Payment{Id: paymentId, Status: "cancelled"}, nil
+		},
+	}
+
+	janitor := &Janitor{Client: client, UserId: "me", MaxAge: 30 * 24 * time.Hour, Remind: true, now: func() time.Time { return now }}
+	report, err := janitor.CleanOnce(context.Background())
+	if err != nil || len(report.Stale) != 2 {
+		t.Fatalf("Cleanup should have handled both stale charges: %+v %v", report, err)
+	}
+	if len(reminders) != 1 || reminders[0] != "Reminder: Dinner" {
+		t.Error("Only the original charge should have been reminded about:", reminders)
+	}
+	if reminder := report.Stale[1]; !reminder.Cancelled || reminder.Reminder != nil {
+		t.Errorf("Stale reminder should have been cancelled without another reminder: %+v", reminder)
+	}
+	if stale := report.Stale[0]; stale.Cancelled || stale.Err == nil || stale.Reminder == nil {
+		t.Errorf("Failed cancel should be reported with its reminder: %+v", stale)
+	}
+
+	failCancel = false
+	payments = payments[:1]
+	report, err = janitor.CleanOnce(context.Background())
+	if err != nil || len(report.Stale) != 1 || !report.Stale[0].Cancelled {
+		t.Fatalf("Retry should have cancelled the charge: %+v %v", report, err)
+	}
+	if len(reminders) != 1 {
+		t.Error("Retry should not have sent another reminder:", reminders)
+	}
+	if len(cancelled) != 2 || cancelled[0] != "reminder" || cancelled[1] != "stale" {
+		t.Error("Both charges should have been cancelled in the end:", cancelled)
+	}
+	if report.Stale[0].Reminder == nil || report.Stale[0].Reminder.Id != "new" {
+		t.Errorf("Retry should report the earlier reminder: %+v", report.Stale[0])
+	}
+}
+
+func TestJanitorRunStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cleanups := 0
+
+	janitor := NewJanitor(&Account{User: User{Id: "me"}})
+	janitor.Client = &stubClient{paymentsSince: func(updatedSince time.Time) ([]Payment, error) { return nil, nil }}
+	janitor.Interval = time.Millisecond
+	janitor.OnCleanup = func(report JanitorReport, err error) {
+		if err != nil {
+			t.Error("Cleanup should not have errored:", err)
+		}
+		cleanups++
+		if cleanups == 3 {
+			cancel()
+		}
+	}
+
+	err := janitor.Run(ctx)
+	if err != context.Canceled || cleanups != 3 {
+		t.Error("Run should have stopped after three cleanups:", cleanups, err)
+	}
+}
@@ -1,239 +1,112 @@
-// Package local_sandbox emulates the Venmo sandbox API for posting payments and charges.
-// It does does emulate retrieving payments or users.
+// Package local_sandbox serves the venmotest Venmo sandbox emulator, by default on
+// port 4000. It emulates posting payments and charges, listing and fetching payments,
+// GET /me, user and friend lookups and OAuth. Other requests get a 404 error unless
+// -proxy is given, in which case they are proxied to the real Venmo sandbox.
+//
+// Every flag can also be set with an environment variable, shown in -help. Flags win.
+//
+// GET /_sandbox/health reports the sandbox is up. POST /_sandbox/reset forgets
+// everything since startup and POST /_sandbox/seed loads users, friendships and
+// payments; both take a venmotest.Seed as JSON.
 package main
 
 import (
-	"encoding/json"
-	"github.com/gorilla/mux"
-	"github.com/gorilla/schema"
-	"io"
+	"context"
+	"flag"
+	"io/ioutil"
 	"log"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
 	"os"
+	"os/signal"
 	"strconv"
-	"strings"
-)
+	"syscall"
+	"time"
 
-var decoder = schema.NewDecoder()
+	"github.com/deet/govenmo/venmotest"
+)
 
-type SandboxRequest struct {
-	AccessToken string `schema:"access_token"`
-	UserId      string `schema:"user_id"`
-	Email       string `schema:"email"`
-	Phone       string `schema:"phone"`
-	Amount      string `schema:"amount"`
-	Note        string `schema:"note"`
-	Audience    string `schema:"audience"`
-}
+// shutdownTimeout is how long in-flight requests get to finish after SIGTERM.
+const shutdownTimeout = 10 * time.Second
 
-type VenmoError struct {
-	Message string `json:"message"`
-	Code    int    `json:"code"`
+func env(name, fallback string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+	return fallback
 }
 
-type ErrorResponse struct {
-	Error VenmoError `json:"error"`
+func envBool(name string) bool {
+	value, _ := strconv.ParseBool(os.Getenv(name))
+	return value
 }
 
 func main() {
-	r := mux.NewRouter()
-	//r.HandleFunc("/payments", NewPaymentHandler).Methods("POST")
-	r.HandleFunc("/payments", PaymentsIndex).Methods("POST")
-	r.HandleFunc("/payments/1111111111111111111", GetPaymentHandler).Methods("GET")
-	r.HandleFunc("/me", MeHandler).Methods("GET")
-
-	r.PathPrefix("/").HandlerFunc(ProxyHandler)
-
-	http.Handle("/", r)
-
-	addr := ":4000"
-	log.Println("Listening on", addr)
-	http.ListenAndServe(addr, nil)
-}
-
-func sandboxUser(request *SandboxRequest) bool {
-	return request.UserId == "145434160922624933" || strings.ToLower(request.Email) == "venmo@venmo.com" || request.Phone == "15555555555"
-}
-
-func ProxyHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Proxying to real sandbox:", r.URL.Path)
-	realSandboxUrl, _ := url.Parse("https://sandbox-api.venmo.com/v1/")
-	httputil.NewSingleHostReverseProxy(realSandboxUrl).ServeHTTP(w, r)
-}
-
-func WriteError(w http.ResponseWriter, status, code int, message string) {
-	b, err := json.Marshal(ErrorResponse{VenmoError{Message: message, Code: code}})
-	if err != nil {
-		http.Error(w, "Sandbox JSON error", 500)
-		return
-	}
-	log.Println("Returning error:", string(b))
-	http.Error(w, string(b), status)
-}
-
-func PaymentsIndex(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	err := r.ParseForm()
-
-	if err != nil {
-		log.Println("Error parsing request:", err)
-		http.Error(w, "Error parsing request.", 500)
-		return
-	}
-
-	var request *SandboxRequest = &SandboxRequest{}
-	decoder.IgnoreUnknownKeys(false)
-	err = decoder.Decode(request, r.PostForm)
-	if err != nil {
-		http.Error(w, "Sandbox error. Could not decode request: "+err.Error(), 500)
-		return
-	}
-	log.Printf("Incoming form: %+v \n", r.PostForm)
-
-	if r.FormValue("access_token") != "" {
-		request.AccessToken = r.FormValue("access_token")
-	}
-
-	if request.AccessToken == "" {
-		log.Println("Missing access token.")
-		WriteError(w, 401, 261, "You did not pass a valid OAuth access token.")
-		return
-	}
-
-	sourceFile := ""
-	amount, _ := strconv.ParseFloat(request.Amount, 64)
-	switch amount {
-	case 0.10:
-		log.Println("0.10 settled payment")
-		sourceFile = "responses/payment/settled.json"
-		if !sandboxUser(request) {
-			sourceFile = "responses/regular_user_error.json"
-			w.WriteHeader(400)
-		}
-	case 0.20:
-		log.Println("0.30 failed payment")
-		sourceFile = "responses/payment/failed.json"
-		if !sandboxUser(request) {
-			sourceFile = "responses/regular_user_error.json"
-			w.WriteHeader(400)
-		}
-	case 0.30:
-		log.Println("0.30 pending payment")
-		sourceFile = "responses/payment/pending.json"
-		if sandboxUser(request) {
-			sourceFile = "responses/payment/pending_error.json"
-			w.WriteHeader(400)
-		}
-	case -0.10:
-		log.Println("-0.10 settled charge")
-		sourceFile = "responses/payment/settled_charge.json"
-	case -0.20:
-		log.Println("-0.20 pending charge")
-		sourceFile = "responses/payment/pending_charge.json"
-		if sandboxUser(request) {
-			sourceFile = "responses/payment/pending_error.json"
-			w.WriteHeader(400)
-		}
+	addr := flag.String("addr", env("VENMO_SANDBOX_ADDR", ":4000"), "listen address (VENMO_SANDBOX_ADDR)")
+	fixtures := flag.String("fixtures", env("VENMO_SANDBOX_FIXTURES", ""), "directory of scenario files served before the built-in routes (VENMO_SANDBOX_FIXTURES)")
+	mode := flag.String("mode", env("VENMO_SANDBOX_MODE", "canned"), "canned or stateful (VENMO_SANDBOX_MODE)")
+	proxy := flag.Bool("proxy", envBool("VENMO_SANDBOX_PROXY"), "proxy routes that are not emulated to "+venmotest.RealSandboxURL+" (VENMO_SANDBOX_PROXY)")
+	logLevel := flag.String("log-level", env("VENMO_SANDBOX_LOG_LEVEL", "info"), "quiet, info or debug, which also logs every request (VENMO_SANDBOX_LOG_LEVEL)")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	var options []venmotest.Option
+	switch *logLevel {
+	case "debug":
+		options = append(options, venmotest.WithLogger(logger))
+	case "info":
+	case "quiet":
+		logger.SetOutput(ioutil.Discard)
 	default:
-		log.Println("Invalid amount:", request.Amount)
-		sourceFile = "responses/invalid_amount.json"
-		w.WriteHeader(400)
-	}
-
-	file, err := os.Open(sourceFile)
-	if err != nil {
-		http.Error(w, "SANDBOX ERROR", 500)
-		log.Println("Sandbox error:", err)
-		return
-	}
-	io.Copy(w, file)
-}
-
-func MeHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	log.Println("/me request")
-
-	err := r.ParseForm()
-
-	if err != nil {
-		log.Println("Error parsing request:", err)
-		http.Error(w, "Error parsing request.", 500)
-		return
-	}
-
-	var request *SandboxRequest = &SandboxRequest{}
-	decoder.IgnoreUnknownKeys(false)
-	err = decoder.Decode(request, r.PostForm)
-	if err != nil {
-		http.Error(w, "Sandbox error. Could not decode request: "+err.Error(), 500)
-		return
+		log.Fatalln("Unknown log level:", *logLevel)
 	}
-	log.Printf("Incoming form: %+v \n", r.PostForm)
 
-	if r.FormValue("access_token") != "" {
-		request.AccessToken = r.FormValue("access_token")
+	switch *mode {
+	case "stateful":
+		options = append(options, venmotest.Stateful())
+	case "canned":
+	default:
+		log.Fatalln("Unknown mode:", *mode)
 	}
 
-	if request.AccessToken == "" {
-		log.Println("Missing access token.")
-		WriteError(w, 401, 261, "You did not pass a valid OAuth access token.")
-		return
+	if *proxy {
+		options = append(options, venmotest.WithProxy(""))
 	}
 
-	sourceFile := "responses/users/me.json"
-
-	file, err := os.Open(sourceFile)
-	if err != nil {
-		http.Error(w, "SANDBOX ERROR", 500)
-		log.Println("Sandbox error:", err)
-		return
-	}
-	io.Copy(w, file)
-}
-
-func GetPaymentHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	log.Println("GET /payments/1111111111111111111 request")
-
-	err := r.ParseForm()
-
-	if err != nil {
-		log.Println("Error parsing request:", err)
-		http.Error(w, "Error parsing request.", 500)
-		return
+	if *fixtures != "" {
+		scenarios, err := venmotest.LoadScenarios(*fixtures)
+		if err != nil {
+			log.Fatalln("Could not load fixtures:", err)
+		}
+		logger.Println("Loaded", len(scenarios), "scenarios from", *fixtures)
+		options = append(options, venmotest.WithScenarios(scenarios...))
 	}
 
-	var request *SandboxRequest = &SandboxRequest{}
-	decoder.IgnoreUnknownKeys(false)
-	err = decoder.Decode(request, r.PostForm)
-	if err != nil {
-		http.Error(w, "Sandbox error. Could not decode request: "+err.Error(), 500)
-		return
+	sandbox := venmotest.NewSandbox(options...)
+	for _, route := range sandbox.Routes() {
+		logger.Println("Emulating", route)
 	}
-	log.Printf("Incoming form: %+v \n", r.PostForm)
 
-	if r.FormValue("access_token") != "" {
-		request.AccessToken = r.FormValue("access_token")
-	}
+	server := &http.Server{Addr: *addr, Handler: sandbox}
 
-	if request.AccessToken == "" {
-		log.Println("Missing access token.")
-		WriteError(w, 401, 261, "You did not pass a valid OAuth access token.")
-		return
-	}
+	stopped := make(chan struct{})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+		received := <-signals
+		logger.Println("Received", received, "- shutting down")
 
-	sourceFile := "responses/payment/get.json"
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			logger.Println("Could not shut down cleanly:", err)
+		}
+		close(stopped)
+	}()
 
-	file, err := os.Open(sourceFile)
-	if err != nil {
-		http.Error(w, "SANDBOX ERROR", 500)
-		log.Println("Sandbox error:", err)
-		return
+	logger.Println("Listening on", *addr, "in", *mode, "mode")
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatalln("Could not serve:", err)
 	}
-	io.Copy(w, file)
+	<-stopped
+	sandbox.WaitForWebhooks()
 }
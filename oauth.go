@@ -0,0 +1,106 @@
+package govenmo
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"strings"
+)
+
+type accessTokenResponse struct {
+	Account
+	Error Error
+}
+
+// AuthorizeURL is where to send a user to grant your app access. Venmo
+// redirects them back to redirectURI with a code for ExchangeCode.
+func AuthorizeURL(clientId string, scopes []string, redirectURI string) string {
+	return authorizeURL(apiRoot(), clientId, scopes, redirectURI)
+}
+
+// AuthorizeURL is like the package-level AuthorizeURL, using the account's APIRoot.
+func (a *Account) AuthorizeURL(clientId string, scopes []string, redirectURI string) string {
+	return authorizeURL(a.apiRoot(), clientId, scopes, redirectURI)
+}
+
+func authorizeURL(root string, clientId string, scopes []string, redirectURI string) string {
+	params := url.Values{}
+	params.Set("client_id", clientId)
+	params.Set("scope", strings.Join(scopes, " "))
+	params.Set("response_type", "code")
+	params.Set("redirect_uri", redirectURI)
+	return root + "/oauth/authorize?" + params.Encode()
+}
+
+// ExchangeCode gets an access token for the code Venmo passed to your
+// redirect URI. The returned Account has its tokens, user and balance filled in.
+func ExchangeCode(clientId, clientSecret, code string) (account *Account, err error) {
+	account = &Account{}
+	err = account.ExchangeCode(clientId, clientSecret, code)
+	if err != nil {
+		account = nil
+	}
+	return
+}
+
+// ExchangeCode is like the package-level ExchangeCode, filling in an
+// Account that may already have its APIRoot set.
+func (a *Account) ExchangeCode(clientId, clientSecret, code string) error {
+	params := url.Values{}
+	params.Set("client_id", clientId)
+	params.Set("client_secret", clientSecret)
+	params.Set("code", code)
+	return a.requestToken(params)
+}
+
+// RefreshAccessToken replaces the Account's access token and refresh token
+// with new ones, using its RefreshToken. The old access token stops working.
+func (a *Account) RefreshAccessToken(clientId, clientSecret string) error {
+	if a.RefreshToken == "" {
+		return errors.New("Account has no refresh token")
+	}
+
+	params := url.Values{}
+	params.Set("client_id", clientId)
+	params.Set("client_secret", clientSecret)
+	params.Set("refresh_token", a.RefreshToken)
+	return a.requestToken(params)
+}
+
+func (a *Account) requestToken(params url.Values) error {
+	logger.Println("Requesting venmo access token")
+
+	resp, err := a.httpClient().PostForm(a.apiRoot()+"/oauth/access_token", params)
+	if err != nil {
+		logger.Println("Could get response from Venmo:", err)
+		return err
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		logger.Println("Could not read response from Venmo:", err)
+		return err
+	}
+
+	var parsedResponse *accessTokenResponse = &accessTokenResponse{}
+	err = json.Unmarshal(body, &parsedResponse)
+	if err != nil {
+		logger.Println("Could not parse response from Venmo:", err)
+		return err
+	}
+
+	if parsedResponse.Error.Message != "" {
+		logger.Println("Error from Venmo API when requesting access token:", parsedResponse.Error.Message)
+		return errors.New(parsedResponse.Error.Message)
+	}
+
+	a.AccessToken = parsedResponse.AccessToken
+	a.RefreshToken = parsedResponse.RefreshToken
+	a.ExpiresIn = parsedResponse.ExpiresIn
+	a.TokenType = parsedResponse.TokenType
+	a.User = parsedResponse.User
+	a.Balance = parsedResponse.Balance
+	return nil
+}
@@ -0,0 +1,75 @@
+package govenmo
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/deet/govenmo/venmotest"
+)
+
+// authorize follows an authorize URL the way a browser would and returns the code.
+func authorize(t *testing.T, authorizeURL string) string {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authorizeURL)
+	if err != nil {
+		t.Fatal("Authorize should not have errored:", err)
+	}
+	resp.Body.Close()
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || location.Query().Get("code") == "" {
+		t.Fatal("Authorize should have redirected with a code:", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	return location.Query().Get("code")
+}
+
+func TestOAuthTokenLifecycle(t *testing.T) {
+	t.Parallel()
+	server := venmotest.NewServer(venmotest.Stateful(), venmotest.RequireIssuedTokens(), venmotest.WithOAuthClient("client", "secret"))
+	defer server.Close()
+
+	account := &Account{APIRoot: server.URL}
+	code := authorize(t, account.AuthorizeURL("client", []string{"access_profile", "make_payments"}, "https://example.com/callback"))
+	if err := account.ExchangeCode("client", "wrong", code); err == nil {
+		t.Error("Exchange should fail with the wrong secret")
+	}
+	if err := account.ExchangeCode("client", "secret", code); err != nil {
+		t.Fatal("Exchange should not have errored:", err)
+	}
+	if account.AccessToken == "" || account.RefreshToken == "" || account.ExpiresIn <= 0 || account.Id != venmotest.MeId || account.Balance != venmotest.DefaultBalance {
+		t.Errorf("Account should have been filled in: %+v", account)
+	}
+	if err := (&Account{APIRoot: server.URL}).ExchangeCode("client", "secret", code); err == nil {
+		t.Error("A code should only be exchanged once")
+	}
+
+	server.Sandbox.ExpireToken(account.AccessToken)
+	if err := account.Refresh(); err == nil {
+		t.Error("Expired token should have been rejected")
+	}
+
+	expired := account.AccessToken
+	if err := account.RefreshAccessToken("client", "secret"); err != nil {
+		t.Fatal("Token refresh should not have errored:", err)
+	}
+	if account.AccessToken == expired {
+		t.Error("Refresh should have issued a new access token")
+	}
+	if err := account.Refresh(); err != nil {
+		t.Error("Refreshed token should work:", err)
+	}
+
+	other := &Account{APIRoot: server.URL}
+	err := other.ExchangeCode("client", "secret", authorize(t, other.AuthorizeURL("client", nil, "https://example.com/callback")+"&user_id="+venmotest.SandboxUserId))
+	if err != nil || other.Refresh() != nil || other.Id != venmotest.SandboxUserId {
+		t.Error("Second account should act as another user:", err)
+	}
+
+	server.Sandbox.RevokeToken(other.AccessToken)
+	if err := other.Refresh(); err == nil {
+		t.Error("Revoked token should have been rejected")
+	}
+	if err := other.RefreshAccessToken("client", "secret"); err == nil {
+		t.Error("Revoked refresh token should have been rejected")
+	}
+}
@@ -1,6 +1,7 @@
 package govenmo
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -47,13 +48,13 @@ func (a *Account) PaymentsSince(updatedSince time.Time) (payments []Payment, err
 		if next != "" {
 			url = next
 		} else {
-			url = apiRoot() + "/payments?"
+			url = a.apiRoot() + "/payments?"
 			url += "after=" + updatedSince.Format(VenmoTimeFormat)
 		}
 		url += "&access_token=" + a.AccessToken
 		logger.Println("Fetching url for recent transactions:", url)
 
-		resp, err := http.Get(url)
+		resp, err := a.httpClient().Get(url)
 		if err != nil {
 			logger.Println("Could get response from Venmo:", err)
 			return payments, err
@@ -112,18 +113,22 @@ func (a *Account) PayOrCharge(target Target, amount float64, note string, audien
 		params.Set("email", target.Email)
 	}
 
+	if target.Phone != "" {
+		params.Set("phone", target.Phone)
+	}
+
 	if target.User.Id != "" {
 		params.Set("user_id", target.User.Id)
 	}
 
-	url := apiRoot() + "/payments"
+	url := a.apiRoot() + "/payments"
 
 	params.Set("note", note)
 	params.Set("amount", fmt.Sprintf("%f", amount))
 	params.Set("audience", audience)
 
 	logger.Printf("Sending venmo payment: %+v\n", params)
-	resp, err := http.PostForm(url, params)
+	resp, err := a.httpClient().PostForm(url, params)
 	if err != nil {
 		logger.Println("Could post payment to Venmo:", err)
 		return
@@ -161,7 +166,7 @@ func (a *Account) CompletePayment(paymentId, action string) (updatedPayment Paym
 
 	params := url.Values{}
 
-	url := apiRoot() + "/payments/" + paymentId + "?access_token=" + a.AccessToken
+	url := a.apiRoot() + "/payments/" + paymentId + "?access_token=" + a.AccessToken
 
 	params.Set("action", action)
 
@@ -175,8 +180,7 @@ func (a *Account) CompletePayment(paymentId, action string) (updatedPayment Paym
 		return
 	}
 
-	client := http.Client{}
-	resp, err := client.Do(req)
+	resp, err := a.httpClient().Do(req)
 	if err != nil {
 		logger.Println("Could not PUT to complete Venmo payment:", err)
 		return
@@ -215,14 +219,25 @@ func (a *Account) CompletePayment(paymentId, action string) (updatedPayment Paym
 }
 
 // RefreshPayment updates a Payment object with the most current state from the Venmo API.
-// For multiple requests using PaymentsSince would be advisable.
+// For many payments, use PaymentsSince, or RefreshPayments when the IDs are known.
 func (a *Account) RefreshPayment(payment *Payment) error {
+	return a.RefreshPaymentContext(context.Background(), payment)
+}
+
+// RefreshPaymentContext is RefreshPayment with a context, which cancels the
+// request when it is done.
+func (a *Account) RefreshPaymentContext(ctx context.Context, payment *Payment) error {
 	if payment == nil {
 		return errors.New("Cannot refresh nil payment")
 	}
 
-	url := apiRoot() + "/payments/" + payment.Id
-	resp, err := http.Get(url + "?access_token=" + a.AccessToken)
+	url := a.apiRoot() + "/payments/" + payment.Id
+	req, err := http.NewRequestWithContext(ctx, "GET", url+"?access_token="+a.AccessToken, nil)
+	if err != nil {
+		logger.Println("Could not create request to refresh Venmo payment:", err)
+		return err
+	}
+	resp, err := a.httpClient().Do(req)
 	if err != nil {
 		logger.Println("Could get response from Venmo:", err)
 		return err
@@ -253,3 +268,21 @@ func (a *Account) RefreshPayment(payment *Payment) error {
 
 	return nil
 }
+
+// contextRefresher is a Client whose payment refreshes take a context, such
+// as *Account.
+type contextRefresher interface {
+	RefreshPaymentContext(ctx context.Context, payment *Payment) error
+}
+
+// refreshPayment refreshes payment through client, passing ctx on if the
+// client takes one. Otherwise ctx is only checked before the refresh.
+func refreshPayment(ctx context.Context, client Client, payment *Payment) error {
+	if refresher, ok := client.(contextRefresher); ok {
+		return refresher.RefreshPaymentContext(ctx, payment)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return client.RefreshPayment(payment)
+}
@@ -2,14 +2,15 @@ package govenmo
 
 import (
 	"testing"
+	"time"
+
+	"github.com/deet/govenmo/venmotest"
 )
 
 func TestPayOrCharge(t *testing.T) {
-	account := &Account{}
-	account.AccessToken = "faketoken"
+	account := testAccount(sandboxURL)
 	target := Target{}
 
-	Environment = "local_sandbox"
 	EnableLogging(nil)
 
 	payment, err := account.PayOrCharge(target, 0.09, "", "public")
@@ -43,10 +44,8 @@ func TestPayOrCharge(t *testing.T) {
 }
 
 func TestPaymentRefresh(t *testing.T) {
-	account := &Account{}
-	account.AccessToken = "faketoken"
+	account := testAccount(sandboxURL)
 
-	Environment = "local_sandbox"
 	EnableLogging(nil)
 
 	payment := &Payment{}
@@ -79,3 +78,70 @@ func TestPaymentRefresh(t *testing.T) {
 	}
 
 }
+
+func TestPaymentsSince(t *testing.T) {
+	account := testAccount(sandboxURL)
+
+	payments, err := account.PaymentsSince(time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Error("Sandbox should not have errored listing payments:", err)
+	}
+	if len(payments) != 1 || payments[0].Id != "1111111111111111111" {
+		t.Error("Wrong payments listed:", payments)
+	}
+
+	payments, err = account.PaymentsSince(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil || len(payments) != 0 {
+		t.Error("No payments should have been listed:", payments, err)
+	}
+}
+
+func TestPaymentsSinceFollowsNext(t *testing.T) {
+	t.Parallel()
+	server := venmotest.NewServer(venmotest.Stateful(), venmotest.WithPageSize(2))
+	defer server.Close()
+
+	me := venmotest.User{Id: venmotest.MeId}
+	for i := 0; i < 5; i++ {
+		server.Sandbox.AddPayment(venmotest.Payment{Status: "pending", Action: "charge", Actor: me, Target: venmotest.Target{Type: "user", User: &venmotest.User{Id: venmotest.SandboxUserId}}, Amount: 1})
+	}
+
+	account := testAccount(server.URL)
+
+	payments, err := account.PaymentsSince(time.Time{})
+	if err != nil {
+		t.Error("Sandbox should not have errored listing payments:", err)
+	}
+	if len(payments) != 5 {
+		t.Error("All pages should have been fetched, got", len(payments))
+	}
+}
+
+func TestPayOrChargeSendsTarget(t *testing.T) {
+	t.Parallel()
+	server := venmotest.NewServer()
+	defer server.Close()
+
+	account := testAccount(server.URL)
+
+	account.PayOrCharge(Target{Phone: "15555555555"}, 0.10, "Lunch", "private")
+	server.Sandbox.AssertPaymentPosted(t,
+		venmotest.WithParam("phone", "15555555555"),
+		venmotest.WithParam("audience", "private"),
+		venmotest.WithParam("note", "Lunch"),
+		venmotest.WithoutParam("email"),
+		venmotest.WithoutParam("user_id"),
+	)
+}
+
+func TestPayOrChargeMatchesScenarioAmount(t *testing.T) {
+	t.Parallel()
+	server := venmotest.NewServer(venmotest.WithScenarioDir("venmotest/testdata/scenarios"))
+	defer server.Close()
+
+	account := testAccount(server.URL)
+	_, err := account.PayOrCharge(Target{Email: "venmo@venmo.com"}, 0.42, "", "private")
+	if err == nil || err.Error() != "Your account is frozen." {
+		t.Error("Frozen account scenario should have matched the amount sent:", err)
+	}
+}
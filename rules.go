@@ -0,0 +1,273 @@
+package govenmo
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Decision is what a Rule does with a pending incoming charge.
+type Decision string
+
+const (
+	DecisionApprove Decision = "approve"
+	DecisionDeny    Decision = "deny"
+	DecisionIgnore  Decision = "ignore"
+)
+
+// Charge is a pending incoming charge as seen by rules.
+type Charge struct {
+	Payment Payment
+	// Age is how long ago the charge was made.
+	Age time.Duration
+	// FromFriend is whether the charge's actor is the account's friend.
+	FromFriend bool
+}
+
+// Condition is a test on a Charge. Conditions are usually made with the
+// functions below, but any func will do.
+type Condition func(charge Charge) bool
+
+// FromActors matches charges made by any of the users with the given IDs.
+func FromActors(userIds ...string) Condition {
+	return func(charge Charge) bool {
+		for _, id := range userIds {
+			if charge.Payment.Actor.Id == id {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// FromFriends matches charges made by the account's friends.
+func FromFriends() Condition {
+	return func(charge Charge) bool { return charge.FromFriend }
+}
+
+// FromStrangers matches charges made by users who are not the account's friends.
+func FromStrangers() Condition {
+	return func(charge Charge) bool { return !charge.FromFriend }
+}
+
+// AmountAtMost matches charges of max or less.
+func AmountAtMost(max float64) Condition {
+	return func(charge Charge) bool { return charge.Payment.Amount <= max }
+}
+
+// AmountAtLeast matches charges of min or more.
+func AmountAtLeast(min float64) Condition {
+	return func(charge Charge) bool { return charge.Payment.Amount >= min }
+}
+
+// NoteMatches matches charges whose note matches pattern.
+func NoteMatches(pattern *regexp.Regexp) Condition {
+	return func(charge Charge) bool { return pattern.MatchString(charge.Payment.Note) }
+}
+
+// AudienceIs matches charges with any of the given audiences.
+func AudienceIs(audiences ...string) Condition {
+	return func(charge Charge) bool {
+		for _, audience := range audiences {
+			if charge.Payment.Audience == audience {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// OlderThan matches charges made more than age ago.
+func OlderThan(age time.Duration) Condition {
+	return func(charge Charge) bool { return charge.Age > age }
+}
+
+// NewerThan matches charges made less than age ago.
+func NewerThan(age time.Duration) Condition {
+	return func(charge Charge) bool { return charge.Age < age }
+}
+
+// Rule decides what to do with charges that meet all of its conditions. A
+// rule without conditions matches every charge.
+type Rule struct {
+	Name     string
+	When     []Condition
+	Decision Decision
+	Reason   string
+}
+
+// Matches reports whether charge meets all of the rule's conditions.
+func (r Rule) Matches(charge Charge) bool {
+	for _, condition := range r.When {
+		if !condition(charge) {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate returns an error if the rule's decision is not approve, deny or
+// ignore.
+func (r Rule) Validate() error {
+	switch r.Decision {
+	case DecisionApprove, DecisionDeny, DecisionIgnore:
+		return nil
+	}
+	return errors.New("Rule " + strconv.Quote(r.Name) + " has unknown decision " + strconv.Quote(string(r.Decision)))
+}
+
+// AuditRecord records one decision made by a RuleEngine. Rule is empty if
+// no rule matched. Err is set if carrying out the decision failed.
+type AuditRecord struct {
+	Time     time.Time
+	Payment  Payment
+	Rule     string
+	Decision Decision
+	Reason   string
+	DryRun   bool
+	Err      error
+}
+
+// RuleEngine handles an account's pending incoming charges by rules. The
+// first rule a charge matches decides it; charges matching no rule are
+// ignored.
+type RuleEngine struct {
+	Client Client
+	// UserId is the ID of the account's user, to whom the charges are made.
+	UserId string
+	Rules  []Rule
+
+	// DryRun decides without approving or denying anything.
+	DryRun bool
+
+	// Lookback limits the charges considered to those updated within it.
+	// Zero considers all of them.
+	Lookback time.Duration
+
+	// Audit, if set, is called with each decision as it is made.
+	Audit func(record AuditRecord)
+
+	now func() time.Time
+}
+
+// NewRuleEngine creates a RuleEngine for an account, which must have been
+// refreshed so that its ID is known.
+func NewRuleEngine(account *Account, rules ...Rule) *RuleEngine {
+	return &RuleEngine{Client: account, UserId: account.Id, Rules: rules}
+}
+
+// Decide returns the decision for charge and the rule that made it.
+func (e *RuleEngine) Decide(charge Charge) (decision Decision, rule Rule) {
+	for _, rule = range e.Rules {
+		if rule.Matches(charge) {
+			return rule.Decision, rule
+		}
+	}
+	return DecisionIgnore, Rule{Reason: "No rule matched"}
+}
+
+// pendingCharges returns the pending charges made to the account, newest first.
+func (e *RuleEngine) pendingCharges(now time.Time) (charges []Charge, err error) {
+	var since time.Time
+	if e.Lookback > 0 {
+		since = now.Add(-e.Lookback)
+	}
+	payments, err := e.Client.PaymentsSince(since)
+	if err != nil {
+		return
+	}
+
+	var friendIds map[string]bool
+	for _, payment := range payments {
+		if payment.Status != "pending" || payment.Action != "charge" || payment.Target.User.Id != e.UserId {
+			continue
+		}
+		charge := Charge{Payment: payment}
+		if payment.DateCreated != nil {
+			charge.Age = now.Sub(payment.DateCreated.Time)
+		}
+
+		if isFriend := payment.Actor.IsFriend; isFriend != nil {
+			charge.FromFriend = *isFriend
+		} else {
+			if friendIds == nil {
+				friendIds = map[string]bool{}
+				friends, fetchErr := e.Client.FetchFriends()
+				if fetchErr != nil {
+					err = fetchErr
+					return
+				}
+				for _, friend := range friends {
+					friendIds[friend.Id] = true
+				}
+			}
+			charge.FromFriend = friendIds[payment.Actor.Id]
+		}
+		charges = append(charges, charge)
+	}
+	return
+}
+
+// Run decides every pending incoming charge once and, unless DryRun is set,
+// approves or denies it. It returns a record of every decision. A failure to
+// complete one charge is recorded and does not stop the others. Run decides
+// nothing if any rule is invalid.
+func (e *RuleEngine) Run(ctx context.Context) (records []AuditRecord, err error) {
+	if e.UserId == "" {
+		err = errors.New("RuleEngine needs the account's user ID, call Refresh first")
+		return
+	}
+	for _, rule := range e.Rules {
+		if err = rule.Validate(); err != nil {
+			return
+		}
+	}
+
+	now := time.Now
+	if e.now != nil {
+		now = e.now
+	}
+
+	charges, err := e.pendingCharges(now())
+	if err != nil {
+		logger.Println("Could not fetch charges for rules:", err)
+		return
+	}
+
+	for _, charge := range charges {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
+		decision, rule := e.Decide(charge)
+		record := AuditRecord{
+			Time:     now().UTC(),
+			Payment:  charge.Payment,
+			Rule:     rule.Name,
+			Decision: decision,
+			Reason:   rule.Reason,
+			DryRun:   e.DryRun,
+		}
+		if (decision == DecisionApprove || decision == DecisionDeny) && !e.DryRun {
+			completed, completeErr := e.Client.CompletePayment(charge.Payment.Id, string(decision))
+			if completeErr != nil {
+				record.Err = completeErr
+			} else {
+				record.Payment = completed
+			}
+		}
+
+		if record.Err != nil {
+			logger.Println("Could not", decision, "charge", charge.Payment.Id, ":", record.Err)
+		} else {
+			logger.Printf("Rules decided to %s charge %s from %s: %s (dry run: %t)\n", decision, charge.Payment.Id, charge.Payment.Actor.Id, record.Reason, e.DryRun)
+		}
+		if e.Audit != nil {
+			e.Audit(record)
+		}
+		records = append(records, record)
+	}
+	return
+}
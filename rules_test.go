@@ -0,0 +1,136 @@
+package govenmo
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestRuleEngine(t *testing.T) {
+	now := time.Date(2014, 8, 18, 17, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) *Time { return &Time{Time: now.Add(-d)} }
+	me, friend, stranger := User{Id: "me"}, User{Id: "friend"}, User{Id: "stranger"}
+	toMe := Target{Type: "user", User: me}
+
+	payments := []Payment{
+		{Id: "small", Status: "pending", Action: "charge", Actor: friend, Target: toMe, Amount: 5, DateCreated: ago(time.Hour)},
+		{Id: "large", Status: "pending", Action: "charge", Actor: friend, Target: toMe, Amount: 500, DateCreated: ago(time.Hour)},
+		{Id: "spam", Status: "pending", Action: "charge", Actor: stranger, Target: toMe, Amount: 1, Note: "WIN a prize", DateCreated: ago(time.Hour)},
+		{Id: "settled", Status: "settled", Action: "charge", Actor: stranger, Target: toMe, Amount: 1},
+		{Id: "outgoing", Status: "pending", Action: "charge", Actor: me, Target: Target{Type: "user", User: friend}, Amount: 1},
+	}
+
+	var completed []string
+	friendFetches := 0
+	client := &stubClient{
+		paymentsSince: func(updatedSince time.Time) ([]Payment, error) {
+			if !updatedSince.Equal(now.Add(-24 * time.Hour)) {
+				t.Error("Engine should have looked back a day, looked since", updatedSince)
+			}
+			return payments, nil
+		},
+		fetchFriends: func() ([]User, error) {
+			friendFetches++
+			return []User{friend}, nil
+		},
+		completePayment: func(paymentId, action string) (Payment, error) {
+			completed = append(completed, paymentId+" "+action)
+			if paymentId == "spam" {
+				return Payment{}, errors.New("You cannot deny this payment.")
+			}
+			return Payment{Id: paymentId, Status: "settled"}, nil
+		},
+	}
+
+	engine := &RuleEngine{
+		Client: client,
+		UserId: "me",
+		Rules: []Rule{
+			{Name: "spam", When: []Condition{FromStrangers(), NoteMatches(regexp.MustCompile(`(?i)prize`))}, Decision: DecisionDeny, Reason: "Looks like spam"},
+			{Name: "small-friends", When: []Condition{FromFriends(), AmountAtMost(10), OlderThan(time.Minute)}, Decision: DecisionApprove, Reason: "Small charge from a friend"},
+		},
+		DryRun:   true,
+		Lookback: 24 * time.Hour,
+		now:      func() time.Time { return now },
+	}
+	var audited []AuditRecord
+	engine.Audit = func(record AuditRecord) { audited = append(audited, record) }
+
+	records, err := engine.Run(context.Background())
+	if err != nil {
+		t.Fatal("Dry run should not have errored:", err)
+	}
+	if len(completed) != 0 {
+		t.Error("Dry run should not have completed anything:", completed)
+	}
+	if len(records) != 3 || len(audited) != 3 || friendFetches != 1 {
+		t.Fatalf("Engine should have decided the three pending incoming charges, fetching friends once: %+v", records)
+	}
+	expected := map[string]Decision{"small": DecisionApprove, "large": DecisionIgnore, "spam": DecisionDeny}
+	for _, record := range records {
+		if record.Decision != expected[record.Payment.Id] || !record.DryRun {
+			t.Errorf("Charge %s should have been decided %s: %+v", record.Payment.Id, expected[record.Payment.Id], record)
+		}
+	}
+	if records[1].Rule != "" || records[1].Reason != "No rule matched" {
+		t.Errorf("Unmatched charge should say so: %+v", records[1])
+	}
+
+	engine.DryRun = false
+	records, err = engine.Run(context.Background())
+	if err != nil {
+		t.Fatal("Live run should not have errored:", err)
+	}
+	if len(completed) != 2 || completed[0] != "small approve" || completed[1] != "spam deny" {
+		t.Error("Live run should have approved and denied by the rules:", completed)
+	}
+	if records[0].Err != nil || records[0].Payment.Status != "settled" || records[2].Err == nil {
+		t.Errorf("Live run should record each outcome: %+v", records)
+	}
+}
+
+func TestRuleEngineRejectsUnknownDecision(t *testing.T) {
+	client := &stubClient{
+		paymentsSince: func(updatedSince time.Time) ([]Payment, error) {
+			t.Error("Engine should not have fetched charges with an invalid rule")
+			return nil, nil
+		},
+		completePayment: func(paymentId, action string) (Payment, error) {
+			t.Error("Engine should not have completed anything:", paymentId, action)
+			return Payment{}, nil
+		},
+	}
+
+	for _, decision := range []Decision{"", "aprove"} {
+		engine := &RuleEngine{Client: client, UserId: "me", Rules: []Rule{
+			{Name: "friends", When: []Condition{FromFriends()}, Decision: DecisionApprove},
+			{Name: "typo", Decision: decision},
+		}}
+		records, err := engine.Run(context.Background())
+		if err == nil || records != nil {
+			t.Errorf("Run should have rejected decision %q: %v %v", decision, records, err)
+		}
+	}
+}
+
+func TestRuleConditions(t *testing.T) {
+	charge := Charge{Payment: Payment{Actor: User{Id: "a"}, Amount: 10, Audience: "private"}, Age: time.Hour}
+
+	matching := []Condition{FromActors("b", "a"), AmountAtLeast(10), AmountAtMost(10), AudienceIs("private"), OlderThan(time.Minute), NewerThan(2 * time.Hour)}
+	for i, condition := range matching {
+		if !condition(charge) {
+			t.Error("Condition should have matched:", i)
+		}
+	}
+	failing := []Condition{FromActors("b"), AmountAtLeast(11), AmountAtMost(9), AudienceIs("public"), OlderThan(2 * time.Hour), NewerThan(time.Minute), FromFriends()}
+	for i, condition := range failing {
+		if condition(charge) {
+			t.Error("Condition should not have matched:", i)
+		}
+	}
+	if !(Rule{}).Matches(charge) {
+		t.Error("Rule without conditions should match everything")
+	}
+}
@@ -1,4 +1,16 @@
 package govenmo
 
+import "net/http"
+
 var Environment string = "production"
 var MaxPayment *float64 = nil
+
+// APIRoot, if set, is used as the Venmo API URL instead of the one chosen by
+// Environment. Account.APIRoot overrides it for one account, which is better
+// in tests because tests with separate servers can then run in parallel.
+var APIRoot string = ""
+
+// HTTPClient makes every request to the Venmo API, except those of an Account
+// with its own HTTPClient. Replace it to set timeouts or to use another
+// http.RoundTripper, such as a venmotest.ReplayTransport.
+var HTTPClient *http.Client = http.DefaultClient
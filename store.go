@@ -0,0 +1,83 @@
+package govenmo
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by a PaymentStore for an unknown payment or an
+// account that has no checkpoint yet.
+var ErrNotFound = errors.New("Not found")
+
+// PaymentStore is where a Syncer keeps payments and per-account checkpoints.
+// MemoryStore implements it, as does the Repository in the store/sql package.
+type PaymentStore interface {
+	GetPayment(id string) (Payment, error)
+	UpsertPayment(payment Payment) error
+	Cursor(accountId string) (time.Time, error)
+	SetCursor(accountId string, syncedTo time.Time) error
+}
+
+// MemoryStore is a PaymentStore that keeps everything in memory.
+// It is safe for concurrent use.
+type MemoryStore struct {
+	mu       sync.Mutex
+	payments map[string]Payment
+	cursors  map[string]time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		payments: map[string]Payment{},
+		cursors:  map[string]time.Time{},
+	}
+}
+
+func (s *MemoryStore) GetPayment(id string) (Payment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	payment, ok := s.payments[id]
+	if !ok {
+		return payment, ErrNotFound
+	}
+	return payment, nil
+}
+
+func (s *MemoryStore) UpsertPayment(payment Payment) error {
+	if payment.Id == "" {
+		return errors.New("Cannot store payment without ID")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.payments[payment.Id] = payment
+	return nil
+}
+
+func (s *MemoryStore) Cursor(accountId string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	syncedTo, ok := s.cursors[accountId]
+	if !ok {
+		return syncedTo, ErrNotFound
+	}
+	return syncedTo, nil
+}
+
+func (s *MemoryStore) SetCursor(accountId string, syncedTo time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cursors[accountId] = syncedTo
+	return nil
+}
+
+// Payments returns every stored payment, in no particular order.
+func (s *MemoryStore) Payments() (payments []Payment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, payment := range s.payments {
+		payments = append(payments, payment)
+	}
+	return
+}
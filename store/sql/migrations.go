@@ -0,0 +1,78 @@
+package sqlstore
+
+import (
+	"database/sql"
+)
+
+// migrations are applied in order; the index plus one is the schema version.
+// Never edit a migration that has shipped, append a new one instead.
+// The SQL is the common subset understood by Postgres (9.5+) and SQLite (3.24+).
+var migrations = []string{
+	`CREATE TABLE users (
+		id                  TEXT PRIMARY KEY,
+		username            TEXT NOT NULL DEFAULT '',
+		display_name        TEXT NOT NULL DEFAULT '',
+		first_name          TEXT NOT NULL DEFAULT '',
+		last_name           TEXT NOT NULL DEFAULT '',
+		email               TEXT,
+		phone               TEXT,
+		about               TEXT NOT NULL DEFAULT '',
+		profile_picture_url TEXT NOT NULL DEFAULT '',
+		friends_count       INTEGER NOT NULL DEFAULT 0,
+		is_friend           BOOLEAN,
+		date_joined         TIMESTAMP
+	)`,
+	`CREATE TABLE payments (
+		id             TEXT PRIMARY KEY,
+		status         TEXT NOT NULL DEFAULT '',
+		action         TEXT NOT NULL DEFAULT '',
+		actor_id       TEXT,
+		amount         DOUBLE PRECISION NOT NULL DEFAULT 0,
+		audience       TEXT NOT NULL DEFAULT '',
+		date_completed TIMESTAMP,
+		date_created   TIMESTAMP,
+		note           TEXT NOT NULL DEFAULT '',
+		target_type    TEXT NOT NULL DEFAULT '',
+		target_email   TEXT NOT NULL DEFAULT '',
+		target_phone   TEXT NOT NULL DEFAULT '',
+		target_user_id TEXT,
+		fee            DOUBLE PRECISION,
+		refund         TEXT,
+		medium         TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX payments_actor_id ON payments (actor_id)`,
+	`CREATE INDEX payments_target_user_id ON payments (target_user_id)`,
+	`CREATE TABLE sync_cursors (
+		account_id TEXT PRIMARY KEY,
+		synced_to  TIMESTAMP NOT NULL
+	)`,
+}
+
+// Migrate brings the database schema up to date. It is safe to call on every start.
+func (r *Repository) Migrate() error {
+	_, err := r.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`)
+	if err != nil {
+		return err
+	}
+
+	var current int
+	err = r.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	for i := current; i < len(migrations); i++ {
+		err = r.inTx(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(migrations[i]); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, i+1)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
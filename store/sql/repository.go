@@ -0,0 +1,281 @@
+// Package sqlstore persists govenmo payments and users with database/sql.
+// It is tested against SQLite and written for Postgres as well; bring your own driver.
+//
+//	db, _ := sql.Open("sqlite3", "venmo.db")
+//	repo := sqlstore.NewRepository(db)
+//	err := repo.Migrate()
+//	err = repo.UpsertPayment(payment)
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/deet/govenmo"
+)
+
+// ErrNotFound is returned when a payment, user or cursor does not exist.
+// It is govenmo.ErrNotFound, so a Repository can back a govenmo.Syncer.
+var ErrNotFound = govenmo.ErrNotFound
+
+// Repository reads and writes govenmo types. Create one with NewRepository
+// and call Migrate before use. It implements govenmo.PaymentStore.
+type Repository struct {
+	db *sql.DB
+}
+
+var _ govenmo.PaymentStore = (*Repository)(nil)
+
+// NewRepository wraps an open database handle.
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func (r *Repository) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	err = fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func nullableString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+const userColumns = `id, username, display_name, first_name, last_name, email, phone,
+	about, profile_picture_url, friends_count, is_friend, date_joined`
+
+const upsertUser = `INSERT INTO users (` + userColumns + `)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+
+func userArgs(user govenmo.User) []interface{} {
+	return []interface{}{
+		user.Id, user.Username, user.DisplayName, user.FirstName, user.LastName, user.Email, user.Phone,
+		user.About, user.ProfilePictureUrl, user.FriendsCount, user.IsFriend, &user.DateJoined,
+	}
+}
+
+// UpsertUser inserts a user or replaces the stored copy.
+func (r *Repository) UpsertUser(user govenmo.User) error {
+	if user.Id == "" {
+		return errors.New("Cannot store user without ID")
+	}
+	_, err := r.db.Exec(upsertUser+` ON CONFLICT (id) DO UPDATE SET
+		username = excluded.username,
+		display_name = excluded.display_name,
+		first_name = excluded.first_name,
+		last_name = excluded.last_name,
+		email = COALESCE(excluded.email, users.email),
+		phone = COALESCE(excluded.phone, users.phone),
+		about = excluded.about,
+		profile_picture_url = excluded.profile_picture_url,
+		friends_count = excluded.friends_count,
+		is_friend = COALESCE(excluded.is_friend, users.is_friend),
+		date_joined = COALESCE(excluded.date_joined, users.date_joined)`, userArgs(user)...)
+	return err
+}
+
+// insertUserIfMissing stores a user seen embedded in a payment. Those copies
+// are partial (no email, phone or friend count) so they never replace a stored user.
+func insertUserIfMissing(q queryer, user govenmo.User) error {
+	if user.Id == "" {
+		return nil
+	}
+	_, err := q.Exec(upsertUser+` ON CONFLICT (id) DO NOTHING`, userArgs(user)...)
+	return err
+}
+
+func scanUser(scanner interface {
+	Scan(dest ...interface{}) error
+}) (user govenmo.User, err error) {
+	err = scanner.Scan(&user.Id, &user.Username, &user.DisplayName, &user.FirstName, &user.LastName, &user.Email, &user.Phone,
+		&user.About, &user.ProfilePictureUrl, &user.FriendsCount, &user.IsFriend, &user.DateJoined)
+	return
+}
+
+func getUser(q queryer, id string) (user govenmo.User, err error) {
+	user, err = scanUser(q.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		err = ErrNotFound
+	}
+	return
+}
+
+// GetUser loads a user by Venmo ID.
+func (r *Repository) GetUser(id string) (govenmo.User, error) {
+	return getUser(r.db, id)
+}
+
+// ListUsers returns all stored users ordered by username.
+func (r *Repository) ListUsers() (users []govenmo.User, err error) {
+	rows, err := r.db.Query(`SELECT ` + userColumns + ` FROM users ORDER BY username, id`)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return users, err
+		}
+		users = append(users, user)
+	}
+	err = rows.Err()
+	return
+}
+
+const paymentColumns = `id, status, action, actor_id, amount, audience, date_completed, date_created,
+	note, target_type, target_email, target_phone, target_user_id, fee, refund, medium`
+
+// UpsertPayment inserts a payment or replaces the stored copy. The actor and
+// target users are stored too if they are not already known.
+func (r *Repository) UpsertPayment(payment govenmo.Payment) error {
+	if payment.Id == "" {
+		return errors.New("Cannot store payment without ID")
+	}
+
+	return r.inTx(func(tx *sql.Tx) error {
+		if err := insertUserIfMissing(tx, payment.Actor); err != nil {
+			return err
+		}
+		if err := insertUserIfMissing(tx, payment.Target.User); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(`INSERT INTO payments (`+paymentColumns+`)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+			ON CONFLICT (id) DO UPDATE SET
+			status = excluded.status,
+			action = excluded.action,
+			actor_id = excluded.actor_id,
+			amount = excluded.amount,
+			audience = excluded.audience,
+			date_completed = excluded.date_completed,
+			date_created = excluded.date_created,
+			note = excluded.note,
+			target_type = excluded.target_type,
+			target_email = excluded.target_email,
+			target_phone = excluded.target_phone,
+			target_user_id = excluded.target_user_id,
+			fee = excluded.fee,
+			refund = excluded.refund,
+			medium = excluded.medium`,
+			payment.Id, payment.Status, payment.Action, nullableString(payment.Actor.Id), payment.Amount, payment.Audience,
+			payment.DateCompleted, payment.DateCreated, payment.Note,
+			payment.Target.Type, payment.Target.Email, payment.Target.Phone, nullableString(payment.Target.User.Id),
+			payment.Fee, payment.Refund, payment.Medium)
+		return err
+	})
+}
+
+func scanPayment(scanner interface {
+	Scan(dest ...interface{}) error
+}) (payment govenmo.Payment, err error) {
+	var actorId, targetUserId sql.NullString
+	err = scanner.Scan(&payment.Id, &payment.Status, &payment.Action, &actorId, &payment.Amount, &payment.Audience,
+		&payment.DateCompleted, &payment.DateCreated, &payment.Note,
+		&payment.Target.Type, &payment.Target.Email, &payment.Target.Phone, &targetUserId,
+		&payment.Fee, &payment.Refund, &payment.Medium)
+	payment.Actor.Id = actorId.String
+	payment.Target.User.Id = targetUserId.String
+	return
+}
+
+// fillUsers replaces the actor and target user IDs with the stored users.
+func fillUsers(q queryer, payment *govenmo.Payment) error {
+	for _, user := range []*govenmo.User{&payment.Actor, &payment.Target.User} {
+		if user.Id == "" {
+			continue
+		}
+		stored, err := getUser(q, user.Id)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		*user = stored
+	}
+	return nil
+}
+
+// GetPayment loads a payment by Venmo ID, including its actor and target user.
+func (r *Repository) GetPayment(id string) (payment govenmo.Payment, err error) {
+	payment, err = scanPayment(r.db.QueryRow(`SELECT `+paymentColumns+` FROM payments WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		err = ErrNotFound
+		return
+	}
+	if err != nil {
+		return
+	}
+	err = fillUsers(r.db, &payment)
+	return
+}
+
+// ListPayments returns the payments a user sent or received, oldest first.
+func (r *Repository) ListPayments(userId string) (payments []govenmo.Payment, err error) {
+	rows, err := r.db.Query(`SELECT `+paymentColumns+` FROM payments
+		WHERE actor_id = $1 OR target_user_id = $1
+		ORDER BY date_created, id`, userId)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			rows.Close()
+			return payments, err
+		}
+		payments = append(payments, payment)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return
+	}
+
+	for i := range payments {
+		if err = fillUsers(r.db, &payments[i]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Cursor returns how far payments for an account have been synced.
+// It returns ErrNotFound if the account has never been synced.
+func (r *Repository) Cursor(accountId string) (syncedTo time.Time, err error) {
+	var stored govenmo.Time
+	err = r.db.QueryRow(`SELECT synced_to FROM sync_cursors WHERE account_id = $1`, accountId).Scan(&stored)
+	if err == sql.ErrNoRows {
+		err = ErrNotFound
+	}
+	syncedTo = stored.Time
+	return
+}
+
+// SetCursor records how far payments for an account have been synced.
+func (r *Repository) SetCursor(accountId string, syncedTo time.Time) error {
+	_, err := r.db.Exec(`INSERT INTO sync_cursors (account_id, synced_to) VALUES ($1, $2)
+		ON CONFLICT (account_id) DO UPDATE SET synced_to = excluded.synced_to`, accountId, syncedTo.UTC())
+	return err
+}
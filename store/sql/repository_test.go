@@ -0,0 +1,196 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/deet/govenmo"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newTestRepository(t *testing.T) *Repository {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal("Could not open SQLite:", err)
+	}
+	// Every connection to :memory: is a separate database.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	repo := NewRepository(db)
+	if err := repo.Migrate(); err != nil {
+		t.Fatal("Migrate should not have errored:", err)
+	}
+	// Migrating twice must be a no-op.
+	if err := repo.Migrate(); err != nil {
+		t.Fatal("Second Migrate should not have errored:", err)
+	}
+	return repo
+}
+
+func testPayment() govenmo.Payment {
+	created := &govenmo.Time{Time: time.Date(2014, 8, 18, 17, 15, 15, 0, time.UTC)}
+	fee := 0.25
+	return govenmo.Payment{
+		Id:          "1111111111111111111",
+		Status:      "pending",
+		Action:      "pay",
+		Actor:       govenmo.User{Id: "123245678901232456789", Username: "keith-brisson", DisplayName: "Keith Brisson"},
+		Amount:      6,
+		Audience:    "private",
+		DateCreated: created,
+		Note:        "The Meatball Shop",
+		Target: govenmo.Target{
+			Type: "user",
+			User: govenmo.User{Id: "987654321012324567890", Username: "someone-else"},
+		},
+		Fee:    &fee,
+		Medium: "api",
+	}
+}
+
+func TestPaymentUpsertAndGet(t *testing.T) {
+	repo := newTestRepository(t)
+	payment := testPayment()
+
+	if err := repo.UpsertPayment(payment); err != nil {
+		t.Fatal("UpsertPayment should not have errored:", err)
+	}
+
+	payment.Status = "settled"
+	payment.DateCompleted = &govenmo.Time{Time: time.Date(2014, 8, 19, 9, 0, 0, 0, time.UTC)}
+	if err := repo.UpsertPayment(payment); err != nil {
+		t.Fatal("Second UpsertPayment should not have errored:", err)
+	}
+
+	stored, err := repo.GetPayment(payment.Id)
+	if err != nil {
+		t.Fatal("GetPayment should not have errored:", err)
+	}
+
+	if stored.Status != "settled" || stored.Amount != 6 || stored.Note != "The Meatball Shop" || *stored.Fee != 0.25 || stored.Refund != nil {
+		t.Errorf("Wrong payment info: %+v", stored)
+	}
+	if !stored.DateCreated.Equal(payment.DateCreated.Time) || !stored.DateCompleted.Equal(payment.DateCompleted.Time) {
+		t.Error("Wrong payment dates:", stored.DateCreated, stored.DateCompleted)
+	}
+	if stored.Actor.Username != "keith-brisson" || stored.Target.User.Username != "someone-else" {
+		t.Error("Payment users were not loaded")
+	}
+
+	if _, err := repo.GetPayment("missing"); err != ErrNotFound {
+		t.Error("Missing payment should return ErrNotFound, got:", err)
+	}
+}
+
+func TestZeroTimesStoredAsNull(t *testing.T) {
+	repo := newTestRepository(t)
+	payment := testPayment()
+	payment.DateCompleted = &govenmo.Time{}
+	if err := repo.UpsertPayment(payment); err != nil {
+		t.Fatal("UpsertPayment should not have errored:", err)
+	}
+
+	var completedNull, joinedNull bool
+	if err := repo.db.QueryRow(`SELECT date_completed IS NULL FROM payments WHERE id = $1`, payment.Id).Scan(&completedNull); err != nil || !completedNull {
+		t.Error("Zero completion date should have been stored as NULL:", err)
+	}
+	if err := repo.db.QueryRow(`SELECT date_joined IS NULL FROM users WHERE id = $1`, payment.Actor.Id).Scan(&joinedNull); err != nil || !joinedNull {
+		t.Error("Zero join date should have been stored as NULL:", err)
+	}
+
+	stored, err := repo.GetPayment(payment.Id)
+	if err != nil || stored.DateCompleted != nil {
+		t.Error("NULL completion date should be read back as nil:", stored.DateCompleted, err)
+	}
+}
+
+func TestListPayments(t *testing.T) {
+	repo := newTestRepository(t)
+
+	first := testPayment()
+	second := testPayment()
+	second.Id = "2222222222222222222"
+	second.DateCreated = &govenmo.Time{Time: first.DateCreated.Add(time.Hour)}
+	second.Actor, second.Target.User = second.Target.User, second.Actor
+	other := testPayment()
+	other.Id = "3333333333333333333"
+	other.Actor = govenmo.User{Id: "1"}
+	other.Target = govenmo.Target{Type: "email", Email: "venmo@venmo.com"}
+
+	for _, payment := range []govenmo.Payment{second, other, first} {
+		if err := repo.UpsertPayment(payment); err != nil {
+			t.Fatal("UpsertPayment should not have errored:", err)
+		}
+	}
+
+	payments, err := repo.ListPayments("123245678901232456789")
+	if err != nil {
+		t.Fatal("ListPayments should not have errored:", err)
+	}
+	if len(payments) != 2 || payments[0].Id != first.Id || payments[1].Id != second.Id {
+		t.Errorf("Wrong payments listed: %+v", payments)
+	}
+}
+
+func TestUserUpsert(t *testing.T) {
+	repo := newTestRepository(t)
+	email := "email@example.com"
+	isFriend := true
+
+	user := govenmo.User{
+		Id:           "123245678901232456789",
+		Username:     "keith-brisson",
+		Email:        &email,
+		FriendsCount: 99,
+		IsFriend:     &isFriend,
+		DateJoined:   govenmo.Time{Time: time.Date(2014, 2, 16, 23, 42, 14, 0, time.UTC)},
+	}
+	if err := repo.UpsertUser(user); err != nil {
+		t.Fatal("UpsertUser should not have errored:", err)
+	}
+
+	// A partial copy embedded in a payment must not wipe the full user.
+	payment := testPayment()
+	if err := repo.UpsertPayment(payment); err != nil {
+		t.Fatal("UpsertPayment should not have errored:", err)
+	}
+
+	stored, err := repo.GetUser(user.Id)
+	if err != nil {
+		t.Fatal("GetUser should not have errored:", err)
+	}
+	if stored.Email == nil || *stored.Email != email || stored.FriendsCount != 99 || stored.IsFriend == nil || !*stored.IsFriend {
+		t.Errorf("Wrong user info: %+v", stored)
+	}
+	if !stored.DateJoined.Equal(user.DateJoined.Time) {
+		t.Error("Wrong date joined:", stored.DateJoined)
+	}
+
+	users, err := repo.ListUsers()
+	if err != nil || len(users) != 2 {
+		t.Error("Expected two users, got", len(users), err)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	repo := newTestRepository(t)
+
+	if _, err := repo.Cursor("account"); err != ErrNotFound {
+		t.Error("Unsynced account should return ErrNotFound, got:", err)
+	}
+
+	syncedTo := time.Date(2014, 8, 18, 17, 15, 15, 0, time.UTC)
+	if err := repo.SetCursor("account", syncedTo); err != nil {
+		t.Fatal("SetCursor should not have errored:", err)
+	}
+	if err := repo.SetCursor("account", syncedTo.Add(time.Hour)); err != nil {
+		t.Fatal("Second SetCursor should not have errored:", err)
+	}
+
+	stored, err := repo.Cursor("account")
+	if err != nil || !stored.Equal(syncedTo.Add(time.Hour)) {
+		t.Error("Wrong cursor:", stored, err)
+	}
+}
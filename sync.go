@@ -0,0 +1,205 @@
+package govenmo
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultSyncOverlap is how far behind its last checkpoint a Syncer starts
+// fetching, because Venmo's 'updated at' filtering is imprecise.
+const DefaultSyncOverlap = 24 * time.Hour
+
+// DefaultSyncInterval is how often Syncer.Run syncs.
+const DefaultSyncInterval = 5 * time.Minute
+
+// StatusChange records a stored payment whose status changed during a sync.
+type StatusChange struct {
+	Payment Payment
+	From    string
+	To      string
+}
+
+// SyncResult reports what a single sync did.
+type SyncResult struct {
+	Fetched       int
+	Created       []Payment
+	Updated       []Payment
+	StatusChanges []StatusChange
+	Events        []Event
+	SyncedTo      time.Time
+}
+
+// Syncer keeps a PaymentStore up to date with an Account's payments.
+// Each sync fetches payments updated since the account's checkpoint minus
+// Overlap, upserts them by ID and moves the checkpoint forward.
+type Syncer struct {
+	Account  *Account
+	Store    PaymentStore
+	Overlap  time.Duration
+	Interval time.Duration
+
+	// OnSync, if set, is called by Run after every sync.
+	OnSync func(result SyncResult, err error)
+
+	// Events, if set, receives an Event for each stored change as it is stored.
+	Events *EventBus
+
+	fetch func(since time.Time) ([]Payment, error)
+	now   func() time.Time
+}
+
+// NewSyncer creates a Syncer with the default overlap and interval.
+func NewSyncer(account *Account, store PaymentStore) *Syncer {
+	return &Syncer{
+		Account:  account,
+		Store:    store,
+		Overlap:  DefaultSyncOverlap,
+		Interval: DefaultSyncInterval,
+	}
+}
+
+// paymentUpdated reports whether anything Venmo may change on an existing
+// payment differs between two copies of it.
+func paymentUpdated(stored, fetched Payment) bool {
+	return stored.Status != fetched.Status ||
+		stored.Amount != fetched.Amount ||
+		stored.Note != fetched.Note ||
+		stored.Audience != fetched.Audience ||
+		!timesEqual(stored.DateCompleted, fetched.DateCompleted) ||
+		!floatsEqual(stored.Fee, fetched.Fee) ||
+		!stringsEqual(stored.Refund, fetched.Refund)
+}
+
+func timesEqual(a, b *Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(b.Time)
+}
+
+func floatsEqual(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func stringsEqual(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// SyncOnce runs a single sync. The checkpoint only moves forward if every
+// fetched payment was stored.
+func (s *Syncer) SyncOnce(ctx context.Context) (result SyncResult, err error) {
+	if s.Account == nil || s.Account.Id == "" {
+		err = errors.New("Syncer needs an Account with an ID, call Refresh first")
+		return
+	}
+
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	fetch := s.Account.PaymentsSince
+	if s.fetch != nil {
+		fetch = s.fetch
+	}
+
+	syncedTo, err := s.Store.Cursor(s.Account.Id)
+	if err != nil && err != ErrNotFound {
+		return
+	}
+
+	var since time.Time
+	if err == nil {
+		since = syncedTo.Add(-s.Overlap)
+	}
+
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	result.SyncedTo = now().UTC()
+	logger.Println("Syncing payments for", s.Account.Id, "since", since)
+	payments, err := fetch(since)
+	if err != nil {
+		logger.Println("Could not fetch payments to sync:", err)
+		return
+	}
+	result.Fetched = len(payments)
+
+	seen := map[string]bool{}
+	for _, payment := range payments {
+		if payment.Id == "" || seen[payment.Id] {
+			continue
+		}
+		seen[payment.Id] = true
+
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
+		old, getErr := s.Store.GetPayment(payment.Id)
+		var previous *Payment
+		switch {
+		case getErr == ErrNotFound:
+			result.Created = append(result.Created, payment)
+		case getErr != nil:
+			err = getErr
+			return
+		case paymentUpdated(old, payment):
+			previous = &old
+			result.Updated = append(result.Updated, payment)
+			if old.Status != payment.Status {
+				result.StatusChanges = append(result.StatusChanges, StatusChange{Payment: payment, From: old.Status, To: payment.Status})
+			}
+		default:
+			continue
+		}
+
+		if err = s.Store.UpsertPayment(payment); err != nil {
+			logger.Println("Could not store synced payment", payment.Id, ":", err)
+			return
+		}
+
+		events := PaymentEvents(s.Account.Id, previous, payment)
+		result.Events = append(result.Events, events...)
+		if s.Events != nil {
+			s.Events.Publish(events...)
+		}
+	}
+
+	err = s.Store.SetCursor(s.Account.Id, result.SyncedTo)
+	return
+}
+
+// Run syncs immediately and then every Interval until ctx is cancelled,
+// which it returns. Sync errors are passed to OnSync and do not stop Run.
+func (s *Syncer) Run(ctx context.Context) error {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultSyncInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := s.SyncOnce(ctx)
+		if err != nil {
+			logger.Println("Payment sync failed:", err)
+		}
+		if s.OnSync != nil && ctx.Err() == nil {
+			s.OnSync(result, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
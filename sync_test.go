@@ -0,0 +1,99 @@
+package govenmo
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSyncOnce(t *testing.T) {
+	account := &Account{}
+	account.Id = "123245678901232456789"
+	store := NewMemoryStore()
+
+	clock := time.Date(2014, 8, 18, 17, 0, 0, 0, time.UTC)
+	var requestedSince []time.Time
+	fetched := []Payment{
+		{Id: "1", Status: "pending", Amount: 1},
+		{Id: "2", Status: "settled", Amount: 2},
+		{Id: "2", Status: "settled", Amount: 2},
+	}
+
+	syncer := NewSyncer(account, store)
+	syncer.Overlap = time.Hour
+	syncer.now = func() time.Time { return clock }
+	syncer.fetch = func(since time.Time) ([]Payment, error) {
+		requestedSince = append(requestedSince, since)
+		return fetched, nil
+	}
+
+	result, err := syncer.SyncOnce(context.Background())
+	if err != nil {
+		t.Fatal("First sync should not have errored:", err)
+	}
+	if len(result.Created) != 2 || len(result.Updated) != 0 || len(store.Payments()) != 2 {
+		t.Errorf("First sync should have created two payments: %+v", result)
+	}
+	if !requestedSince[0].IsZero() {
+		t.Error("First sync should fetch everything, fetched since", requestedSince[0])
+	}
+
+	clock = clock.Add(5 * time.Minute)
+	fetched = []Payment{
+		{Id: "1", Status: "settled", Amount: 1},
+		{Id: "2", Status: "settled", Amount: 2},
+		{Id: "3", Status: "pending", Amount: 3},
+	}
+
+	result, err = syncer.SyncOnce(context.Background())
+	if err != nil {
+		t.Fatal("Second sync should not have errored:", err)
+	}
+	if !requestedSince[1].Equal(clock.Add(-5*time.Minute - time.Hour)) {
+		t.Error("Second sync should overlap the checkpoint, fetched since", requestedSince[1])
+	}
+	if len(result.Created) != 1 || result.Created[0].Id != "3" {
+		t.Errorf("Second sync should have created payment 3: %+v", result.Created)
+	}
+	if len(result.Updated) != 1 || len(result.StatusChanges) != 1 {
+		t.Fatalf("Second sync should have updated payment 1: %+v", result)
+	}
+	change := result.StatusChanges[0]
+	if change.Payment.Id != "1" || change.From != "pending" || change.To != "settled" {
+		t.Errorf("Wrong status change: %+v", change)
+	}
+
+	stored, _ := store.GetPayment("1")
+	syncedTo, _ := store.Cursor(account.Id)
+	if stored.Status != "settled" || !syncedTo.Equal(clock) {
+		t.Error("Store was not updated:", stored.Status, syncedTo)
+	}
+}
+
+func TestSyncRunStopsOnCancel(t *testing.T) {
+	account := &Account{}
+	account.Id = "123245678901232456789"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	syncs := 0
+
+	syncer := NewSyncer(account, NewMemoryStore())
+	syncer.Interval = time.Millisecond
+	syncer.fetch = func(since time.Time) ([]Payment, error) {
+		return nil, nil
+	}
+	syncer.OnSync = func(result SyncResult, err error) {
+		if err != nil {
+			t.Error("Sync should not have errored:", err)
+		}
+		syncs++
+		if syncs == 3 {
+			cancel()
+		}
+	}
+
+	err := syncer.Run(ctx)
+	if err != context.Canceled || syncs != 3 {
+		t.Error("Run should have stopped after three syncs:", syncs, err)
+	}
+}
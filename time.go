@@ -1,48 +1,115 @@
 package govenmo
 
 import (
+	"bytes"
 	"database/sql/driver"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 )
 
 // Venmo use a time format that's not compatible with Go's default.
 // The custom Time type allows parsing their time format.
 // It is also designed to be insertable into a Postgres DB (see Scan).
+// Venmo times carry no zone and are treated as UTC throughout.
 type Time struct {
 	time.Time
 }
 
+// Value implements driver.Valuer. A nil or zero Time is written as NULL,
+// which Scan reads back as a zero Time.
 func (venmoTime *Time) Value() (driver.Value, error) {
-	if venmoTime == nil {
+	if venmoTime == nil || venmoTime.IsZero() {
 		return nil, nil
 	}
-	return venmoTime.Time, nil
+	return venmoTime.Time.UTC(), nil
 }
 
 var timestamptzFormat2 = "2006-01-02 15:04:05.999999999-07"
 
+// sqlTimeFormats are the layouts Scan tries, in order, for text values.
+// They cover Postgres timestamp/timestamptz, SQLite's default and RFC 3339
+// forms, MySQL DATETIME and DATE, and Venmo's own format.
+var sqlTimeFormats = []string{
+	timestamptzFormat2,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	VenmoTimeFormat,
+	"2006-01-02",
+}
+
+// Scan implements sql.Scanner. It accepts time.Time, string, []byte and nil
+// (which leaves a zero Time).
 func (venmoTime *Time) Scan(src interface{}) error {
-	stringVal := string(src.([]byte))
-	parsedTime, err := time.Parse(timestamptzFormat2, stringVal)
-	venmoTime.Time = parsedTime
-	return err
+	switch v := src.(type) {
+	case nil:
+		venmoTime.Time = time.Time{}
+		return nil
+	case time.Time:
+		venmoTime.Time = v.UTC()
+		return nil
+	case []byte:
+		return venmoTime.scanString(string(v))
+	case string:
+		return venmoTime.scanString(v)
+	default:
+		return fmt.Errorf("Cannot scan %T into Time", src)
+	}
+}
+
+func (venmoTime *Time) scanString(s string) error {
+	for _, format := range sqlTimeFormats {
+		parsedTime, err := time.Parse(format, s)
+		if err == nil {
+			venmoTime.Time = parsedTime.UTC()
+			return nil
+		}
+	}
+	return fmt.Errorf("Cannot parse %q as a time", s)
 }
 
 const VenmoTimeFormat = "2006-01-02T15:04:05"
 
-// UnmarshalJSON([]byte) error
+// venmoTimeOutputFormat is VenmoTimeFormat with optional fractional seconds,
+// which Venmo includes on some timestamps (e.g. date_created).
+const venmoTimeOutputFormat = "2006-01-02T15:04:05.999999999"
+
+var jsonNull = []byte("null")
 
+// MarshalJSON writes the time in Venmo's format, in UTC. A zero Time is
+// written as null.
+func (venmoTime Time) MarshalJSON() ([]byte, error) {
+	if venmoTime.IsZero() {
+		return jsonNull, nil
+	}
+	if year := venmoTime.UTC().Year(); year < 0 || year > 9999 {
+		return nil, errors.New("Time year outside of range [0,9999]")
+	}
+	return json.Marshal(venmoTime.UTC().Format(venmoTimeOutputFormat))
+}
+
+// UnmarshalJSON parses Venmo's format. JSON null leaves the Time unchanged.
+// Times with an explicit zone are converted to UTC.
 func (venmoTime *Time) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), jsonNull) {
+		return nil
+	}
 	var s string
 	if err := json.Unmarshal(data, &s); err != nil {
 		return err
 	}
 	t, err := time.Parse(VenmoTimeFormat, s)
+	if err != nil {
+		t, err = time.Parse(time.RFC3339Nano, s)
+	}
 	if err != nil {
 		logger.Println("Couldnt not parse time:", err)
 		return err
 	}
-	venmoTime.Time = t
+	venmoTime.Time = t.UTC()
 	return nil
 }
@@ -0,0 +1,154 @@
+package govenmo
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimeJSONRoundTrip(t *testing.T) {
+	input := []byte(`{"id": "1", "date_created": "2013-12-30T19:40:57.865985", "date_completed": null}`)
+
+	payment := Payment{}
+	err := json.Unmarshal(input, &payment)
+	if err != nil {
+		t.Fatal("Payment should have parsed:", err)
+	}
+	if payment.DateCompleted != nil {
+		t.Error("Null date_completed should be nil")
+	}
+
+	encoded, err := json.Marshal(payment)
+	if err != nil {
+		t.Fatal("Payment should have encoded:", err)
+	}
+
+	decoded := Payment{}
+	err = json.Unmarshal(encoded, &decoded)
+	if err != nil {
+		t.Fatal("Encoded payment should have parsed:", err, string(encoded))
+	}
+	if !decoded.DateCreated.Equal(payment.DateCreated.Time) || decoded.DateCompleted != nil {
+		t.Error("Times did not survive a round trip:", string(encoded))
+	}
+}
+
+func TestTimeUnmarshalJSON(t *testing.T) {
+	var venmoTime Time
+	if err := json.Unmarshal([]byte(`null`), &venmoTime); err != nil || !venmoTime.IsZero() {
+		t.Error("null should leave a zero time:", err)
+	}
+
+	if err := json.Unmarshal([]byte(`"2014-02-16T23:42:14"`), &venmoTime); err != nil {
+		t.Error("Venmo time should have parsed:", err)
+	}
+	if venmoTime.Location() != time.UTC || venmoTime.Hour() != 23 {
+		t.Error("Venmo time should be UTC")
+	}
+
+	if err := json.Unmarshal([]byte(`"2014-02-16T18:42:14-05:00"`), &venmoTime); err != nil {
+		t.Error("Zoned time should have parsed:", err)
+	}
+	if venmoTime.Location() != time.UTC || venmoTime.Hour() != 23 {
+		t.Error("Zoned time should be converted to UTC")
+	}
+
+	if err := json.Unmarshal([]byte(`"16/02/2014"`), &venmoTime); err == nil {
+		t.Error("Bad time should have errored")
+	}
+}
+
+func TestTimeScan(t *testing.T) {
+	expected := time.Date(2014, 2, 16, 23, 42, 14, 0, time.UTC)
+
+	sources := []interface{}{
+		[]byte("2014-02-16 18:42:14-05"),
+		"2014-02-16 23:42:14+00:00",
+		"2014-02-16 23:42:14Z",
+		"2014-02-16T23:42:14Z",
+		"2014-02-16 23:42:14",
+		"2014-02-16T23:42:14",
+		expected.In(time.FixedZone("EST", -5*60*60)),
+	}
+
+	for _, src := range sources {
+		var venmoTime Time
+		if err := venmoTime.Scan(src); err != nil {
+			t.Errorf("Scan(%v) should not have errored: %v", src, err)
+			continue
+		}
+		if !venmoTime.Equal(expected) || venmoTime.Location() != time.UTC {
+			t.Errorf("Scan(%v) gave %v", src, venmoTime.Time)
+		}
+	}
+
+	venmoTime := Time{expected}
+	if err := venmoTime.Scan(nil); err != nil || !venmoTime.IsZero() {
+		t.Error("Scan(nil) should leave a zero time:", err)
+	}
+
+	if err := venmoTime.Scan(42); err == nil {
+		t.Error("Scan(int) should have errored")
+	}
+}
+
+func TestTimeValue(t *testing.T) {
+	expected := time.Date(2014, 2, 16, 23, 42, 14, 0, time.UTC)
+	if value, err := (&Time{expected.In(time.FixedZone("EST", -5*60*60))}).Value(); err != nil || value != expected {
+		t.Error("Value should be the time in UTC:", value, err)
+	}
+
+	var missing *Time
+	for _, venmoTime := range []*Time{missing, {}} {
+		if value, err := venmoTime.Value(); err != nil || value != nil {
+			t.Error("Nil and zero times should be NULL:", value, err)
+		}
+	}
+}
+
+func FuzzTimeUnmarshalJSON(f *testing.F) {
+	f.Add([]byte(`"2014-02-16T23:42:14"`))
+	f.Add([]byte(`"2013-12-30T19:40:57.865985"`))
+	f.Add([]byte(`"2014-02-16T18:42:14-05:00"`))
+	f.Add([]byte(`null`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var parsed Time
+		if err := parsed.UnmarshalJSON(data); err != nil {
+			return
+		}
+
+		encoded, err := json.Marshal(parsed)
+		if err != nil {
+			return
+		}
+
+		var reparsed Time
+		if err := json.Unmarshal(encoded, &reparsed); err != nil {
+			t.Fatalf("%s encoded to %s which did not parse: %v", data, encoded, err)
+		}
+		if !reparsed.Equal(parsed.Time) {
+			t.Fatalf("%s round tripped to %v, expected %v", data, reparsed.Time, parsed.Time)
+		}
+	})
+}
+
+func FuzzTimeScan(f *testing.F) {
+	f.Add("2014-02-16 18:42:14-05")
+	f.Add("2014-02-16 23:42:14.123456")
+	f.Add("2014-02-16T23:42:14Z")
+	f.Add("2014-02-16")
+
+	f.Fuzz(func(t *testing.T, src string) {
+		var fromString, fromBytes Time
+		stringErr := fromString.Scan(src)
+		bytesErr := fromBytes.Scan([]byte(src))
+
+		if (stringErr == nil) != (bytesErr == nil) || !fromString.Equal(fromBytes.Time) {
+			t.Fatalf("string and []byte scans of %q disagree", src)
+		}
+		if stringErr == nil && fromString.Location() != time.UTC {
+			t.Fatalf("Scan(%q) was not UTC", src)
+		}
+	})
+}
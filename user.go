@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"io/ioutil"
-	"net/http"
 )
 
 type User struct {
@@ -32,12 +31,12 @@ func (account *Account) FetchFriends() (friends []User, err error) {
 		if next != "" {
 			url = next
 		} else {
-			url = apiRoot() + "/users/" + account.Id + "/friends?"
+			url = account.apiRoot() + "/users/" + account.Id + "/friends?"
 		}
 		url += "&access_token=" + account.AccessToken
 		logger.Println("Fetching url for user's friends:", url)
 
-		resp, err := http.Get(url)
+		resp, err := account.httpClient().Get(url)
 		if err != nil {
 			logger.Println("Could get response from Venmo:", err)
 			return friends, err
@@ -0,0 +1,40 @@
+package govenmo
+
+import (
+	"testing"
+
+	"github.com/deet/govenmo/venmotest"
+)
+
+func TestFetchFriends(t *testing.T) {
+	account := testAccount(sandboxURL)
+	account.Id = venmotest.MeId
+
+	friends, err := account.FetchFriends()
+	if err != nil {
+		t.Error("Sandbox should not have errored fetching friends:", err)
+	}
+	if len(friends) != 2 || friends[0].Id != venmotest.SandboxUserId || friends[0].IsFriend == nil || !*friends[0].IsFriend {
+		t.Errorf("Wrong friends: %+v", friends)
+	}
+
+	account.Id = "nobody"
+	_, err = account.FetchFriends()
+	if err == nil {
+		t.Error("Sandbox should have errored on an unknown user")
+	}
+}
+
+func TestFetchFriendsFollowsNext(t *testing.T) {
+	t.Parallel()
+	server := venmotest.NewServer(venmotest.WithPageSize(1))
+	defer server.Close()
+
+	account := testAccount(server.URL)
+	account.Id = venmotest.MeId
+
+	friends, err := account.FetchFriends()
+	if err != nil || len(friends) != 2 {
+		t.Error("All pages should have been fetched:", len(friends), err)
+	}
+}
@@ -0,0 +1,138 @@
+package venmotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+)
+
+// SeedUser is a user to add with Seed. Balance defaults to DefaultBalance.
+type SeedUser struct {
+	User
+	Balance *float64 `json:"balance"`
+}
+
+// Seed is data to load into a sandbox between test suites. Friendships are
+// pairs of user IDs. Payments are stored as they are, without moving money,
+// and need a stateful sandbox.
+type Seed struct {
+	Users       []SeedUser  `json:"users"`
+	Friendships [][2]string `json:"friendships"`
+	Payments    []Payment   `json:"payments"`
+}
+
+func (g *graph) clone() *graph {
+	clone := &graph{users: map[string]User{}, friends: map[string][]string{}}
+	for id, user := range g.users {
+		clone.users[id] = user
+	}
+	clone.order = append([]string{}, g.order...)
+	for id, friends := range g.friends {
+		clone.friends[id] = append([]string{}, friends...)
+	}
+	return clone
+}
+
+// Reset puts the sandbox back the way it was created. Payments, balances,
+// users and friendships added since, issued tokens, recorded requests and
+// webhook deliveries are forgotten, and scheduled settlements and webhook
+// deliveries in progress are cancelled. Faults and webhook URLs are kept.
+func (sb *Sandbox) Reset() {
+	// Scheduled settlements and deliveries in progress are dropped first, so
+	// none of them touch the fresh state.
+	sb.webhooks.reset()
+
+	initial := sb.initialGraph.clone()
+	sb.graph.mu.Lock()
+	sb.graph.users, sb.graph.order, sb.graph.friends = initial.users, initial.order, initial.friends
+	sb.graph.mu.Unlock()
+
+	if s := sb.state; s != nil {
+		fresh := newState()
+		s.mu.Lock()
+		s.members, s.memberOrder = fresh.members, nil
+		s.payments, s.paymentOrder = fresh.payments, nil
+		s.nextId = fresh.nextId
+		for _, id := range initial.order {
+			s.addUser(initial.users[id], DefaultBalance)
+		}
+		s.mu.Unlock()
+	}
+
+	t := sb.tokens
+	t.mu.Lock()
+	t.byAccess, t.byRefresh, t.codes = map[string]*Token{}, map[string]*Token{}, map[string]string{}
+	t.mu.Unlock()
+
+	sb.ClearRequests()
+
+	sb.logger.Println("Sandbox reset")
+}
+
+// Seed adds users, friendships and payments to the sandbox.
+func (sb *Sandbox) Seed(seed Seed) error {
+	if len(seed.Payments) > 0 && sb.state == nil {
+		return errors.New("Payments can only be seeded into a stateful sandbox")
+	}
+
+	for _, user := range seed.Users {
+		balance := DefaultBalance
+		if user.Balance != nil {
+			balance = *user.Balance
+		}
+		sb.AddUser(user.User, balance)
+	}
+	for _, friendship := range seed.Friendships {
+		sb.AddFriendship(friendship[0], friendship[1])
+	}
+	for _, payment := range seed.Payments {
+		sb.AddPayment(payment)
+	}
+
+	sb.logger.Printf("Seeded %d users, %d friendships and %d payments\n", len(seed.Users), len(seed.Friendships), len(seed.Payments))
+	return nil
+}
+
+// serveHealth answers GET /_sandbox/health so that scripts can wait for the
+// sandbox to come up.
+func (sb *Sandbox) serveHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	mode := "canned"
+	if sb.state != nil {
+		mode = "stateful"
+	}
+	sb.writeJSON(w, map[string]string{"status": "ok", "mode": mode})
+}
+
+// serveReset answers POST /_sandbox/reset. If the body is a Seed, it is
+// loaded after the reset.
+func (sb *Sandbox) serveReset(w http.ResponseWriter, r *http.Request) {
+	sb.Reset()
+	sb.serveSeed(w, r)
+}
+
+// serveSeed answers POST /_sandbox/seed with a Seed in the body. An empty
+// body seeds nothing.
+func (sb *Sandbox) serveSeed(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		sb.writeError(w, 400, codeInvalidRequest, "Could not read body.")
+		return
+	}
+	var seed Seed
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &seed); err != nil {
+			sb.writeError(w, 400, codeInvalidRequest, "Could not parse seed: "+err.Error())
+			return
+		}
+	}
+	if err := sb.Seed(seed); err != nil {
+		sb.writeError(w, 400, codeInvalidRequest, err.Error())
+		return
+	}
+	sb.writeJSON(w, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,83 @@
+package venmotest
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	t.Parallel()
+	server := NewServer(Stateful())
+	defer server.Close()
+
+	var health map[string]string
+	resp := getJSON(t, server.URL+"/_sandbox/health", &health)
+	if resp.StatusCode != 200 || health["status"] != "ok" || health["mode"] != "stateful" {
+		t.Error("Health should report the mode:", health)
+	}
+}
+
+func TestResetAndSeed(t *testing.T) {
+	t.Parallel()
+	server := NewServer(Stateful())
+	defer server.Close()
+
+	var created testPaymentResponse
+	postJSON(t, server.URL+"/payments", url.Values{"access_token": {"token"}, "user_id": {SandboxUserId}, "amount": {"10"}}, &created)
+	server.Sandbox.AddUser(User{Id: "1"}, 5)
+	token := server.Sandbox.IssueToken(MeId)
+
+	resp, err := http.Post(server.URL+"/_sandbox/reset", "application/json", nil)
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatal("Reset should not have errored:", err)
+	}
+	resp.Body.Close()
+
+	if _, ok := server.Sandbox.Payment(created.Data.Payment.Id); ok || server.Sandbox.Balance(MeId) != DefaultBalance || server.Sandbox.Balance("1") != 0 {
+		t.Error("Payments, balances and users should have been reset")
+	}
+	if len(server.Sandbox.Tokens()) != 0 || len(server.Sandbox.Requests()) != 0 {
+		t.Error("Tokens and requests should have been forgotten:", token)
+	}
+
+	seed := `{
+		"users": [{"id": "42", "username": "new-friend", "balance": 12.5}],
+		"friendships": [["` + MeId + `", "42"]],
+		"payments": [{"id": "7", "status": "pending", "action": "charge", "amount": 3, "actor": {"id": "42"}, "target": {"type": "user", "user": {"id": "` + MeId + `"}}, "date_created": "2014-08-18T17:15:15.865985"}]
+	}`
+	resp, err = http.Post(server.URL+"/_sandbox/seed", "application/json", strings.NewReader(seed))
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatal("Seed should not have errored:", err, resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	payment, ok := server.Sandbox.Payment("7")
+	if !ok || payment.Actor.Id != "42" || server.Sandbox.Balance("42") != 12.5 {
+		t.Errorf("Seed should have been loaded: %+v", payment)
+	}
+	var friends struct{ Data []userView }
+	getJSON(t, server.URL+"/users/"+MeId+"/friends?access_token=token", &friends)
+	if len(friends.Data) != 3 || friends.Data[2].Username != "new-friend" {
+		t.Errorf("Seeded friendship should be listed: %+v", friends.Data)
+	}
+
+	server.Sandbox.Reset()
+	getJSON(t, server.URL+"/users/"+MeId+"/friends?access_token=token", &friends)
+	if len(friends.Data) != 2 {
+		t.Errorf("Reset should restore the social graph: %+v", friends.Data)
+	}
+}
+
+func TestSeedNeedsState(t *testing.T) {
+	t.Parallel()
+	server := NewServer()
+	defer server.Close()
+
+	resp, _ := http.Post(server.URL+"/_sandbox/seed", "application/json", strings.NewReader(`{"payments": [{"id": "1"}]}`))
+	resp.Body.Close()
+	if resp.StatusCode != 400 {
+		t.Error("Payments should not be seeded into a canned sandbox:", resp.StatusCode)
+	}
+}
@@ -0,0 +1,350 @@
+package venmotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Scrubbed replaces tokens and personal data in cassettes.
+const Scrubbed = "[scrubbed]"
+
+// scrubbedParams are request parameters whose values never reach a cassette.
+// access_token is dropped entirely, so that replays match whatever token the
+// client uses.
+var scrubbedParams = map[string]bool{
+	"access_token":  true,
+	"refresh_token": true,
+	"client_secret": true,
+	"code":          true,
+	"email":         true,
+	"phone":         true,
+	"user_id":       true,
+	"note":          true,
+}
+
+// scrubbedFields are JSON fields in response bodies whose values never reach
+// a cassette: tokens, and anything that names or describes a person.
+var scrubbedFields = map[string]bool{
+	"access_token":        true,
+	"refresh_token":       true,
+	"email":               true,
+	"phone":               true,
+	"username":            true,
+	"display_name":        true,
+	"first_name":          true,
+	"last_name":           true,
+	"about":               true,
+	"profile_picture_url": true,
+	"note":                true,
+}
+
+// scrubbedHeaders are response headers that are not recorded. Content-Length
+// goes too because scrubbing changes the body's length.
+var scrubbedHeaders = []string{"Set-Cookie", "Content-Length"}
+
+// tokenInURL finds tokens in URLs inside response bodies, such as pagination links.
+var tokenInURL = regexp.MustCompile(`(access_token|refresh_token)=[^&"\s]*`)
+
+// Interaction is one recorded request and the response it got.
+type Interaction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+// CassetteRequest is what replays are matched on. Params holds the query and
+// form parameters, scrubbed and without access_token.
+type CassetteRequest struct {
+	Method string     `json:"method"`
+	Path   string     `json:"path"`
+	Params url.Values `json:"params"`
+}
+
+// CassetteResponse is a recorded response, scrubbed.
+type CassetteResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body"`
+}
+
+// Cassette is a list of interactions, stored as a JSON file.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// LoadCassette reads a cassette file.
+func LoadCassette(path string) (cassette *Cassette, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	cassette = &Cassette{}
+	err = json.Unmarshal(data, cassette)
+	return
+}
+
+// Save writes the cassette to path.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// readRequestBody reads r's body without modifying r, as the
+// http.RoundTripper contract requires. It returns the request to send on,
+// which is r itself unless r's body had to be consumed and is replaced in a
+// clone.
+func readRequestBody(r *http.Request) (send *http.Request, body []byte, err error) {
+	send = r
+	if r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+	if r.GetBody != nil {
+		var copied io.ReadCloser
+		if copied, err = r.GetBody(); err != nil {
+			return
+		}
+		defer copied.Close()
+		body, err = ioutil.ReadAll(copied)
+		return
+	}
+
+	body, err = ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	send = r.Clone(r.Context())
+	send.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return
+}
+
+// cassetteRequest returns the parts of r, with the given body, that replays
+// match on.
+func cassetteRequest(r *http.Request, body []byte) CassetteRequest {
+	params := url.Values{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		params, _ = url.ParseQuery(string(body))
+	}
+	for key, values := range r.URL.Query() {
+		params[key] = append(params[key], values...)
+	}
+	for key := range params {
+		if key == "access_token" {
+			params.Del(key)
+		} else if scrubbedParams[key] {
+			params[key] = []string{Scrubbed}
+		}
+	}
+	return CassetteRequest{Method: r.Method, Path: r.URL.Path, Params: params}
+}
+
+func (c CassetteRequest) matches(other CassetteRequest) bool {
+	return c.Method == other.Method && c.Path == other.Path && c.Params.Encode() == other.Params.Encode()
+}
+
+// scrubBody removes tokens and personal data from a response body. JSON
+// bodies have the values of scrubbedFields replaced.
+func scrubBody(body []byte) string {
+	body = tokenInURL.ReplaceAll(body, []byte("$1="+url.QueryEscape(Scrubbed)))
+
+	var parsed interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if decoder.Decode(&parsed) != nil {
+		return string(body)
+	}
+	scrubbed, err := json.Marshal(scrubValue(parsed))
+	if err != nil {
+		return string(body)
+	}
+	return string(scrubbed)
+}
+
+func scrubValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if scrubbedFields[key] && field != nil {
+				value[key] = Scrubbed
+			} else {
+				value[key] = scrubValue(field)
+			}
+		}
+	case []interface{}:
+		for i := range value {
+			value[i] = scrubValue(value[i])
+		}
+	}
+	return value
+}
+
+func scrubHeader(header http.Header) http.Header {
+	header = header.Clone()
+	for _, key := range scrubbedHeaders {
+		header.Del(key)
+	}
+	return header
+}
+
+// RecordingTransport is an http.RoundTripper that records every request and
+// response it passes on to a cassette. Call Save when done.
+type RecordingTransport struct {
+	// Transport sends the requests. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	path     string
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecordingTransport creates a RecordingTransport that saves to path.
+func NewRecordingTransport(path string, transport http.RoundTripper) *RecordingTransport {
+	return &RecordingTransport{Transport: transport, path: path}
+}
+
+func (t *RecordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	send, requestBody, err := readRequestBody(r)
+	if err != nil {
+		return nil, err
+	}
+	request := cassetteRequest(r, requestBody)
+
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(send)
+	if err != nil {
+		return resp, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return resp, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cassette.Interactions = append(t.cassette.Interactions, Interaction{
+		Request:  request,
+		Response: CassetteResponse{Status: resp.StatusCode, Header: scrubHeader(resp.Header), Body: scrubBody(body)},
+	})
+	return resp, nil
+}
+
+// Cassette returns what has been recorded so far.
+func (t *RecordingTransport) Cassette() *Cassette {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &Cassette{Interactions: append([]Interaction{}, t.cassette.Interactions...)}
+}
+
+// Save writes the recorded interactions to the transport's path.
+func (t *RecordingTransport) Save() error {
+	return t.Cassette().Save(t.path)
+}
+
+// ReplayTransport is an http.RoundTripper that answers requests from a
+// cassette without sending them. A request is answered by the first
+// interaction with the same method, path and parameters, ignoring the host
+// and access token. Each interaction is played once, so repeated requests get
+// successive recorded responses; once all are used the last one is repeated,
+// and requests matching no interaction get a Venmo-style 404 error. In Strict
+// mode, both of those fail with an error instead.
+type ReplayTransport struct {
+	Strict bool
+
+	mu       sync.Mutex
+	cassette *Cassette
+	played   []bool
+}
+
+// NewReplayTransport creates a ReplayTransport playing the cassette at path.
+func NewReplayTransport(path string) (*ReplayTransport, error) {
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayTransportFor(cassette), nil
+}
+
+// NewReplayTransportFor creates a ReplayTransport playing cassette.
+func NewReplayTransportFor(cassette *Cassette) *ReplayTransport {
+	return &ReplayTransport{cassette: cassette, played: make([]bool, len(cassette.Interactions))}
+}
+
+func (t *ReplayTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	_, body, err := readRequestBody(r)
+	if r.Body != nil {
+		r.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	request := cassetteRequest(r, body)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last := -1
+	for i, interaction := range t.cassette.Interactions {
+		if !interaction.Request.matches(request) {
+			continue
+		}
+		if !t.played[i] {
+			t.played[i] = true
+			return replayResponse(r, interaction.Response), nil
+		}
+		last = i
+	}
+
+	if t.Strict {
+		return nil, errors.New("venmotest: no unplayed interaction for " + r.Method + " " + r.URL.Path + "?" + request.Params.Encode())
+	}
+	if last >= 0 {
+		return replayResponse(r, t.cassette.Interactions[last].Response), nil
+	}
+
+	notFound, _ := json.Marshal(errorResponse{venmoError{Message: "Resource not found. " + r.Method + " " + r.URL.Path + " is not in the cassette.", Code: codeNotFound}})
+	return replayResponse(r, CassetteResponse{Status: 404, Header: http.Header{"Content-Type": {"application/json"}}, Body: string(notFound)}), nil
+}
+
+// Unplayed returns the interactions that no request has matched yet.
+func (t *ReplayTransport) Unplayed() (unplayed []Interaction) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, interaction := range t.cassette.Interactions {
+		if !t.played[i] {
+			unplayed = append(unplayed, interaction)
+		}
+	}
+	return
+}
+
+func replayResponse(r *http.Request, recorded CassetteResponse) *http.Response {
+	header := recorded.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(recorded.Status) + " " + http.StatusText(recorded.Status),
+		StatusCode:    recorded.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(strings.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       r,
+	}
+}
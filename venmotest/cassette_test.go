@@ -0,0 +1,142 @@
+package venmotest
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	t.Parallel()
+	server := NewServer(Stateful())
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recording := NewRecordingTransport(path, nil)
+	client := &http.Client{Transport: recording}
+
+	var recorded struct{ Data struct{ Balance string } }
+	resp, err := client.PostForm(server.URL+"/payments", url.Values{"access_token": {"secret-token"}, "email": {"venmo@venmo.com"}, "amount": {"10"}})
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatal("POST should have been sent:", err)
+	}
+	resp.Body.Close()
+	resp, err = client.Get(server.URL + "/me?access_token=secret-token")
+	if err != nil {
+		t.Fatal("GET should have been sent:", err)
+	}
+	resp.Body.Close()
+	if err := recording.Save(); err != nil {
+		t.Fatal("Cassette should have been saved:", err)
+	}
+	server.Close()
+
+	saved, _ := ioutil.ReadFile(path)
+	if strings.Contains(string(saved), "secret-token") || strings.Contains(string(saved), "venmo@venmo.com") || strings.Contains(string(saved), "email@example.com") || strings.Contains(string(saved), "keith-brisson") {
+		t.Error("Tokens and personal data should have been scrubbed:", string(saved))
+	}
+
+	replay, err := NewReplayTransport(path)
+	if err != nil {
+		t.Fatal("Cassette should have loaded:", err)
+	}
+	replay.Strict = true
+	client = &http.Client{Transport: replay}
+
+	resp, err = client.Get("https://api.venmo.com/me?access_token=other-token")
+	if err != nil {
+		t.Fatal("GET should have been replayed:", err)
+	}
+	json.NewDecoder(resp.Body).Decode(&recorded)
+	resp.Body.Close()
+	if recorded.Data.Balance != "90.00" {
+		t.Error("Recorded balance should have been replayed:", recorded.Data.Balance)
+	}
+	if len(replay.Unplayed()) != 1 {
+		t.Error("POST should not have been played yet:", replay.Unplayed())
+	}
+
+	_, err = client.PostForm(server.URL+"/payments", url.Values{"access_token": {"x"}, "email": {"someone@example.com"}, "amount": {"20"}})
+	if err == nil {
+		t.Error("Strict replay should fail on an unmatched request")
+	}
+	resp, err = client.PostForm(server.URL+"/payments", url.Values{"access_token": {"x"}, "email": {"someone@example.com"}, "amount": {"10"}})
+	if err != nil || resp.StatusCode != 200 {
+		t.Error("Matching POST should have been replayed:", err)
+	}
+	resp.Body.Close()
+	if _, err = client.Get("https://api.venmo.com/me"); err == nil {
+		t.Error("Strict replay should fail once an interaction is used up")
+	}
+
+	replay.Strict = false
+	resp, err = client.Get("https://api.venmo.com/me")
+	if err != nil || resp.StatusCode != 200 {
+		t.Error("Used interactions should repeat outside strict mode:", err)
+	}
+	resp.Body.Close()
+	resp, err = client.Get("https://api.venmo.com/payments/1")
+	if err != nil || resp.StatusCode != 404 {
+		t.Error("Unmatched requests should get a 404 outside strict mode:", err)
+	}
+	resp.Body.Close()
+}
+
+func TestRecordingTransportLeavesRequestAlone(t *testing.T) {
+	t.Parallel()
+	server := NewServer()
+	defer server.Close()
+	recording := NewRecordingTransport(filepath.Join(t.TempDir(), "cassette.json"), nil)
+
+	for _, withGetBody := range []bool{true, false} {
+		body := ioutil.NopCloser(strings.NewReader("amount=0.10&email=venmo%40venmo.com"))
+		r, _ := http.NewRequest("POST", server.URL+"/payments?access_token=faketoken", body)
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if withGetBody {
+			r.GetBody = func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(strings.NewReader("amount=0.10&email=venmo%40venmo.com")), nil
+			}
+		}
+
+		resp, err := recording.RoundTrip(r)
+		if err != nil {
+			t.Fatal("POST should have been sent:", err)
+		}
+		resp.Body.Close()
+		if r.Body != body {
+			t.Error("RoundTrip should not replace the request's body, with GetBody:", withGetBody)
+		}
+	}
+
+	if len(server.Sandbox.RequestsTo("POST", "/payments")) != 2 {
+		t.Error("Both POSTs should have reached the sandbox")
+	}
+	for _, request := range server.Sandbox.RequestsTo("POST", "/payments") {
+		if request.Param("email") != "venmo@venmo.com" {
+			t.Error("Sandbox should have received the form:", request.Form)
+		}
+	}
+	for _, interaction := range recording.Cassette().Interactions {
+		if interaction.Request.Params.Get("amount") != "0.10" {
+			t.Error("Cassette should have recorded the form:", interaction.Request.Params)
+		}
+	}
+}
+
+func TestScrubBody(t *testing.T) {
+	body := scrubBody([]byte(`{"pagination": {"next": "https://api.venmo.com/v1/payments?access_token=abc&limit=2"}, "data": [{"id": 1322585332520059420, "email": "a@b.com", "phone": null, "note": "Rent for Jane", ` +
+		`"actor": {"username": "jane-doe", "display_name": "Jane Doe", "first_name": "Jane", "last_name": "Doe", "profile_picture_url": "https://example.com/jane.png"}, "error": {"code": 261}}]}`))
+	for _, private := range []string{"abc", "a@b.com", "Jane", "Doe", "jane-doe", "jane.png", "Rent"} {
+		if strings.Contains(body, private) {
+			t.Error("Tokens, personal data and notes should have been scrubbed:", private, body)
+		}
+	}
+	if !strings.Contains(body, "1322585332520059420") || !strings.Contains(body, `"code":261`) || !strings.Contains(body, `"phone":null`) {
+		t.Error("Other values should be untouched:", body)
+	}
+}
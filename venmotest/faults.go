@@ -0,0 +1,247 @@
+package venmotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Fault makes matching requests misbehave. A request matches if its method
+// and path match; Path is a path.Match pattern such as "/payments/*", and
+// empty Method or Path match anything. Matching requests are counted per
+// fault: the first After are left alone and the next Times (or all, if Times
+// is 0) get the fault.
+//
+// Latency delays the response and can be combined with anything else. Then,
+// in order of precedence:
+//
+//   - FailAfterHandling serves the request, so a payment is really created
+//     or completed, but answers with Status (500 if unset) instead.
+//   - Status answers with a Venmo error envelope without serving the
+//     request. A 429 carries a Retry-After of RetryAfter seconds (1 if unset).
+//   - Drop sends the headers and half of the body, then closes the connection.
+//   - Truncate sends only the first half of the body.
+//   - Malformed replaces the body with invalid JSON.
+type Fault struct {
+	Method            string        `json:"method"`
+	Path              string        `json:"path"`
+	After             int           `json:"after"`
+	Times             int           `json:"times"`
+	Latency           time.Duration `json:"-"`
+	Status            int           `json:"status"`
+	RetryAfter        int           `json:"retry_after"`
+	FailAfterHandling bool          `json:"fail_after_handling"`
+	Drop              bool          `json:"drop"`
+	Truncate          bool          `json:"truncate"`
+	Malformed         bool          `json:"malformed"`
+}
+
+// MarshalJSON writes Latency as a duration string like "250ms".
+func (f Fault) MarshalJSON() ([]byte, error) {
+	type plain Fault
+	wire := struct {
+		plain
+		Latency string `json:"latency,omitempty"`
+	}{plain: plain(f)}
+	if f.Latency != 0 {
+		wire.Latency = f.Latency.String()
+	}
+	return json.Marshal(wire)
+}
+
+// UnmarshalJSON reads Latency as a duration string like "250ms".
+func (f *Fault) UnmarshalJSON(data []byte) error {
+	type plain Fault
+	var wire struct {
+		plain
+		Latency string `json:"latency"`
+	}
+	err := json.Unmarshal(data, &wire)
+	if err != nil {
+		return err
+	}
+	*f = Fault(wire.plain)
+	if wire.Latency != "" {
+		f.Latency, err = time.ParseDuration(wire.Latency)
+	}
+	return err
+}
+
+type activeFault struct {
+	Fault
+	seen int
+}
+
+type faults struct {
+	mu     sync.Mutex
+	active []*activeFault
+}
+
+// WithFaults starts the sandbox with fault rules. More can be added with
+// AddFault or through the admin endpoint.
+func WithFaults(faults ...Fault) Option {
+	return func(sb *Sandbox) {
+		for _, fault := range faults {
+			sb.AddFault(fault)
+		}
+	}
+}
+
+// AddFault adds a fault rule. Rules are checked in the order they were added
+// and the first one that applies to a request wins.
+func (sb *Sandbox) AddFault(fault Fault) {
+	sb.faults.mu.Lock()
+	defer sb.faults.mu.Unlock()
+	sb.faults.active = append(sb.faults.active, &activeFault{Fault: fault})
+}
+
+// ClearFaults removes every fault rule.
+func (sb *Sandbox) ClearFaults() {
+	sb.faults.mu.Lock()
+	defer sb.faults.mu.Unlock()
+	sb.faults.active = nil
+}
+
+// Faults lists the fault rules.
+func (sb *Sandbox) Faults() []Fault {
+	sb.faults.mu.Lock()
+	defer sb.faults.mu.Unlock()
+	list := []Fault{}
+	for _, active := range sb.faults.active {
+		list = append(list, active.Fault)
+	}
+	return list
+}
+
+// match counts the request against every rule it matches and returns the
+// first rule that applies to it, or nil.
+func (f *faults) match(r *http.Request) *Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var applied *Fault
+	for _, active := range f.active {
+		if active.Method != "" && active.Method != r.Method {
+			continue
+		}
+		if active.Path != "" {
+			if matched, _ := path.Match(active.Path, r.URL.Path); !matched {
+				continue
+			}
+		}
+		active.seen++
+		if applied == nil && active.seen > active.After && (active.Times == 0 || active.seen <= active.After+active.Times) {
+			fault := active.Fault
+			applied = &fault
+		}
+	}
+	return applied
+}
+
+// serveWithFault serves r as usual, misbehaving as fault says.
+func (sb *Sandbox) serveWithFault(fault *Fault, w http.ResponseWriter, r *http.Request) {
+	sb.logger.Printf("Injecting fault into %s %s: %+v\n", r.Method, r.URL.Path, *fault)
+
+	if fault.Latency > 0 {
+		select {
+		case <-time.After(fault.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if fault.Status != 0 && !fault.FailAfterHandling {
+		sb.writeFaultStatus(w, fault)
+		return
+	}
+
+	recorder := httptest.NewRecorder()
+	sb.serve(recorder, r)
+	body := recorder.Body.Bytes()
+
+	if fault.FailAfterHandling {
+		sb.logger.Println("Served request, now failing the response")
+		sb.writeFaultStatus(w, fault)
+		return
+	}
+
+	for key, values := range recorder.Header() {
+		w.Header()[key] = values
+	}
+
+	switch {
+	case fault.Drop:
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(recorder.Code)
+		w.Write(body[:len(body)/2])
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		// Aborting the handler makes net/http close the connection mid-body.
+		panic(http.ErrAbortHandler)
+	case fault.Truncate:
+		body = body[:len(body)/2]
+	case fault.Malformed:
+		body = []byte(`{"data": {"id": "1111111111111111111", "status": ]`)
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(recorder.Code)
+	w.Write(body)
+}
+
+func (sb *Sandbox) writeFaultStatus(w http.ResponseWriter, fault *Fault) {
+	status := fault.Status
+	if status == 0 {
+		status = 500
+	}
+	if status == http.StatusTooManyRequests {
+		retryAfter := fault.RetryAfter
+		if retryAfter <= 0 {
+			retryAfter = 1
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	sb.writeError(w, status, status, "Injected fault: "+http.StatusText(status))
+}
+
+// serveFaultsAdmin lists (GET), adds (POST, a Fault or a list of them) and
+// clears (DELETE) fault rules.
+func (sb *Sandbox) serveFaultsAdmin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case "POST":
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			sb.writeError(w, 400, codeInvalidRequest, "Could not read body.")
+			return
+		}
+		var added []Fault
+		if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+			err = json.Unmarshal(body, &added)
+		} else {
+			var fault Fault
+			err = json.Unmarshal(body, &fault)
+			added = []Fault{fault}
+		}
+		if err != nil {
+			sb.writeError(w, 400, codeInvalidRequest, "Could not parse faults: "+err.Error())
+			return
+		}
+		for _, fault := range added {
+			sb.AddFault(fault)
+		}
+	case "DELETE":
+		sb.ClearFaults()
+	}
+
+	json.NewEncoder(w).Encode(sb.Faults())
+}
@@ -0,0 +1,129 @@
+package venmotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestFaultStatusAndCount(t *testing.T) {
+	t.Parallel()
+	server := NewServer(WithFaults(Fault{Method: "GET", Path: "/payments/*", After: 1, Times: 1, Status: 429, RetryAfter: 7}))
+	defer server.Close()
+
+	statuses := []int{}
+	for i := 0; i < 3; i++ {
+		resp, err := http.Get(server.URL + "/payments/1111111111111111111?access_token=token")
+		if err != nil {
+			t.Fatal("GET should not have errored:", err)
+		}
+		resp.Body.Close()
+		statuses = append(statuses, resp.StatusCode)
+		if resp.StatusCode == 429 && resp.Header.Get("Retry-After") != "7" {
+			t.Error("429 should carry Retry-After:", resp.Header.Get("Retry-After"))
+		}
+	}
+	if statuses[0] != 200 || statuses[1] != 429 || statuses[2] != 200 {
+		t.Error("Only the second request should have failed:", statuses)
+	}
+
+	resp, _ := http.Get(server.URL + "/me?access_token=token")
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Error("Other routes should not be affected:", resp.StatusCode)
+	}
+}
+
+func TestFaultLatency(t *testing.T) {
+	t.Parallel()
+	server := NewServer(WithFaults(Fault{Path: "/me", Latency: 50 * time.Millisecond}))
+	defer server.Close()
+
+	start := time.Now()
+	var parsed struct{ Data struct{ Balance string } }
+	resp := getJSON(t, server.URL+"/me?access_token=token", &parsed)
+	if time.Since(start) < 50*time.Millisecond || resp.StatusCode != 200 || parsed.Data.Balance != "1.23" {
+		t.Error("Response should be delayed but correct:", time.Since(start), resp.StatusCode, parsed)
+	}
+}
+
+func TestFaultBrokenBodies(t *testing.T) {
+	t.Parallel()
+	server := NewServer()
+	defer server.Close()
+
+	for _, fault := range []Fault{{Drop: true}, {Truncate: true}, {Malformed: true}} {
+		server.Sandbox.ClearFaults()
+		server.Sandbox.AddFault(fault)
+
+		resp, err := http.Get(server.URL + "/me?access_token=token")
+		if err != nil {
+			t.Fatal("GET should not have errored:", err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		var parsed interface{}
+		if fault.Drop && err == nil {
+			t.Error("Dropped connection should fail reading the body")
+		}
+		if !fault.Drop && (err != nil || json.Unmarshal(body, &parsed) == nil) {
+			t.Errorf("Body should be readable but not valid JSON for %+v: %s %v", fault, body, err)
+		}
+	}
+}
+
+func TestFaultAfterHandling(t *testing.T) {
+	t.Parallel()
+	server := NewServer(Stateful(), WithFaults(Fault{Method: "POST", Path: "/payments", Times: 1, FailAfterHandling: true, Status: 503}))
+	defer server.Close()
+
+	resp, err := http.PostForm(server.URL+"/payments", url.Values{"access_token": {"token"}, "user_id": {SandboxUserId}, "amount": {"10"}})
+	if err != nil {
+		t.Fatal("POST should not have errored:", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 503 {
+		t.Error("Response should have failed:", resp.StatusCode)
+	}
+	if server.Sandbox.Balance(SandboxUserId) != DefaultBalance+10 {
+		t.Error("Payment should still have been made:", server.Sandbox.Balance(SandboxUserId))
+	}
+}
+
+func TestFaultsAdmin(t *testing.T) {
+	t.Parallel()
+	server := NewServer()
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/_sandbox/faults", "application/json", bytes.NewBufferString(`{"path": "/me", "status": 500, "latency": "1ms"}`))
+	if err != nil {
+		t.Fatal("POST should not have errored:", err)
+	}
+	var listed []Fault
+	json.NewDecoder(resp.Body).Decode(&listed)
+	resp.Body.Close()
+	if len(listed) != 1 || listed[0].Status != 500 || listed[0].Latency != time.Millisecond {
+		t.Errorf("Fault should have been added: %+v", listed)
+	}
+
+	resp, _ = http.Get(server.URL + "/me?access_token=token")
+	resp.Body.Close()
+	if resp.StatusCode != 500 {
+		t.Error("Fault should apply:", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest("DELETE", server.URL+"/_sandbox/faults", nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+
+	resp, _ = http.Get(server.URL + "/me?access_token=token")
+	resp.Body.Close()
+	if resp.StatusCode != 200 || len(server.Sandbox.Faults()) != 0 {
+		t.Error("Faults should have been cleared:", resp.StatusCode)
+	}
+}
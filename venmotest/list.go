@@ -0,0 +1,197 @@
+package venmotest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DefaultPageSize is how many payments or friends are returned per page when
+// the request has no limit.
+const DefaultPageSize = 50
+
+// WithPageSize sets the default page size of GET /payments and
+// GET /users/{id}/friends, so that small data sets still exercise a client's pagination.
+func WithPageSize(size int) Option {
+	return func(sb *Sandbox) {
+		sb.pageSize = size
+	}
+}
+
+type pagination struct {
+	Next string `json:"next,omitempty"`
+}
+
+type listResponse struct {
+	Pagination pagination  `json:"pagination"`
+	Data       interface{} `json:"data"`
+}
+
+// listTimeFormats are accepted for the after and before parameters.
+var listTimeFormats = []string{venmoTimeFormat, time.RFC3339Nano, "2006-01-02"}
+
+func parseListTime(value string) (parsed time.Time, err error) {
+	for _, format := range listTimeFormats {
+		parsed, err = time.Parse(format, value)
+		if err == nil {
+			return
+		}
+	}
+	return
+}
+
+// pageParams reads limit and offset, falling back to the sandbox's page size.
+func (sb *Sandbox) pageParams(query url.Values) (limit, offset int, ok bool) {
+	limit = sb.pageSize
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			return 0, 0, false
+		}
+		limit = parsed
+	}
+	if value := query.Get("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return 0, 0, false
+		}
+		offset = parsed
+	}
+	return limit, offset, true
+}
+
+// nextPageURL is an absolute link to the page after this one. It keeps the
+// request's filters but not its access token, which clients add themselves.
+func nextPageURL(r *http.Request, limit, offset int) string {
+	query := r.URL.Query()
+	query.Del("access_token")
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	next := url.URL{Scheme: scheme, Host: r.Host, Path: r.URL.Path, RawQuery: query.Encode()}
+	return next.String()
+}
+
+// writePage writes one page of items, with a next link if there are more.
+func (sb *Sandbox) writePage(w http.ResponseWriter, r *http.Request, items []interface{}) {
+	limit, offset, ok := sb.pageParams(r.URL.Query())
+	if !ok {
+		sb.writeError(w, 400, codeInvalidRequest, "Invalid limit or offset.")
+		return
+	}
+
+	response := listResponse{Data: []interface{}{}}
+	if offset < len(items) {
+		end := offset + limit
+		if end < len(items) {
+			response.Pagination.Next = nextPageURL(r, limit, end)
+		} else {
+			end = len(items)
+		}
+		response.Data = items[offset:end]
+	}
+
+	b, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "Sandbox JSON error", 500)
+		return
+	}
+	w.Write(b)
+}
+
+// cannedPayments are listed by GET /payments outside stateful mode.
+func cannedPayments() ([]Payment, error) {
+	b, err := responses.ReadFile("responses/payment/get.json")
+	if err != nil {
+		return nil, err
+	}
+	var parsed struct {
+		Data Payment
+	}
+	err = json.Unmarshal(b, &parsed)
+	return []Payment{parsed.Data}, err
+}
+
+// listPayments serves GET /payments. Payments updated after the after
+// parameter and before the before parameter are returned newest first.
+func (sb *Sandbox) listPayments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	sb.logger.Println("GET /payments request:", r.URL.RawQuery)
+
+	request := sb.parseRequest(w, r)
+	if request == nil {
+		return
+	}
+
+	var after, before time.Time
+	var err error
+	query := r.URL.Query()
+	if value := query.Get("after"); value != "" {
+		if after, err = parseListTime(value); err != nil {
+			sb.writeError(w, 400, codeInvalidRequest, "Invalid after time.")
+			return
+		}
+	}
+	if value := query.Get("before"); value != "" {
+		if before, err = parseListTime(value); err != nil {
+			sb.writeError(w, 400, codeInvalidRequest, "Invalid before time.")
+			return
+		}
+	}
+
+	var payments []Payment
+	if sb.state != nil {
+		payments = sb.state.paymentsFor(request.actorId)
+	} else if payments, err = cannedPayments(); err != nil {
+		http.Error(w, "SANDBOX ERROR", 500)
+		sb.logger.Println("Sandbox error:", err)
+		return
+	}
+
+	var matched []Payment
+	for _, payment := range payments {
+		updated := payment.updated()
+		if (after.IsZero() || updated.After(after)) && (before.IsZero() || updated.Before(before)) {
+			matched = append(matched, payment)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i].updated(), matched[j].updated()
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return matched[i].Id > matched[j].Id
+	})
+
+	items := make([]interface{}, len(matched))
+	for i, payment := range matched {
+		items[i] = payment
+	}
+	sb.writePage(w, r, items)
+}
+
+// paymentsFor returns copies of the payments a user sent or received.
+func (s *state) paymentsFor(userId string) (payments []Payment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range s.paymentOrder {
+		payment := s.payments[id]
+		if payment.Actor.Id == userId || (payment.Target.User != nil && payment.Target.User.Id == userId) {
+			payments = append(payments, *payment)
+		}
+	}
+	return
+}
@@ -0,0 +1,76 @@
+package venmotest
+
+import (
+	"testing"
+	"time"
+)
+
+type testListResponse struct {
+	Pagination struct {
+		Next string
+	}
+	Data []Payment
+}
+
+func TestListPayments(t *testing.T) {
+	t.Parallel()
+	server := NewServer(Stateful(), WithPageSize(2))
+	defer server.Close()
+
+	me := User{Id: MeId}
+	someoneElse := User{Id: SomeoneElseId}
+	start := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		server.Sandbox.AddPayment(Payment{Status: "settled", Action: "pay", Actor: me, Target: Target{Type: "user", User: &someoneElse}, Amount: 1, DateCreated: start.Add(time.Duration(i) * time.Hour)})
+	}
+	// Not visible to MeId.
+	server.Sandbox.AddPayment(Payment{Status: "settled", Action: "pay", Actor: someoneElse, Target: Target{Type: "email"}, Amount: 1, DateCreated: start})
+
+	var ids []string
+	next := server.URL + "/payments?after=2015-01-01T00:30:00"
+	pages := 0
+	for next != "" {
+		var parsed testListResponse
+		getJSON(t, next+"&access_token=token", &parsed)
+		for _, payment := range parsed.Data {
+			ids = append(ids, payment.Id)
+		}
+		next = parsed.Pagination.Next
+		pages++
+	}
+
+	if pages != 2 || len(ids) != 4 {
+		t.Fatalf("Expected 4 payments over 2 pages, got %d over %d: %v", len(ids), pages, ids)
+	}
+	for i := 1; i < len(ids); i++ {
+		first, _ := server.Sandbox.Payment(ids[i-1])
+		second, _ := server.Sandbox.Payment(ids[i])
+		if !first.DateCreated.After(second.DateCreated) {
+			t.Error("Payments should be newest first:", ids)
+		}
+	}
+
+	var parsed testListResponse
+	getJSON(t, server.URL+"/payments?access_token=token&before=2015-01-01T01:30:00&limit=10", &parsed)
+	if len(parsed.Data) != 2 || parsed.Pagination.Next != "" {
+		t.Errorf("before should filter and limit should override the page size: %+v", parsed)
+	}
+}
+
+func TestListCannedPayments(t *testing.T) {
+	t.Parallel()
+	server := NewServer()
+	defer server.Close()
+
+	var parsed testListResponse
+	getJSON(t, server.URL+"/payments?access_token=token&after=2014-01-01T00:00:00", &parsed)
+	if len(parsed.Data) != 1 || parsed.Data[0].Id != "1111111111111111111" {
+		t.Errorf("Canned payment should be listed: %+v", parsed)
+	}
+
+	parsed = testListResponse{}
+	getJSON(t, server.URL+"/payments?access_token=token&after=2015-01-01T00:00:00", &parsed)
+	if len(parsed.Data) != 0 {
+		t.Errorf("Canned payment should be filtered out: %+v", parsed)
+	}
+}
@@ -0,0 +1,273 @@
+package venmotest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultTokenLifetime is how long tokens issued by the sandbox last, the
+// same as Venmo's.
+const DefaultTokenLifetime = 60 * 24 * time.Hour
+
+// Token is an access token issued by the sandbox, and the user it acts as.
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	UserId       string
+	ExpiresAt    time.Time
+	Revoked      bool
+}
+
+type tokens struct {
+	mu           sync.Mutex
+	byAccess     map[string]*Token
+	byRefresh    map[string]*Token
+	codes        map[string]string
+	lifetime     time.Duration
+	requireKnown bool
+	clientId     string
+	clientSecret string
+	now          func() time.Time
+}
+
+func newTokens() *tokens {
+	return &tokens{
+		byAccess:  map[string]*Token{},
+		byRefresh: map[string]*Token{},
+		codes:     map[string]string{},
+		lifetime:  DefaultTokenLifetime,
+		now:       time.Now,
+	}
+}
+
+// WithTokenLifetime sets how long issued access tokens last.
+func WithTokenLifetime(lifetime time.Duration) Option {
+	return func(sb *Sandbox) {
+		sb.tokens.lifetime = lifetime
+	}
+}
+
+// RequireIssuedTokens makes the sandbox reject access tokens it did not
+// issue. By default any other non-empty token acts as the user MeId.
+func RequireIssuedTokens() Option {
+	return func(sb *Sandbox) {
+		sb.tokens.requireKnown = true
+	}
+}
+
+// WithOAuthClient makes POST /oauth/access_token check the client ID and
+// secret. By default any client is accepted.
+func WithOAuthClient(id, secret string) Option {
+	return func(sb *Sandbox) {
+		sb.tokens.clientId = id
+		sb.tokens.clientSecret = secret
+	}
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("venmotest: could not generate token: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+func (t *tokens) issue(userId string) Token {
+	token := &Token{
+		AccessToken:  randomToken(),
+		RefreshToken: randomToken(),
+		UserId:       userId,
+		ExpiresAt:    t.now().Add(t.lifetime),
+	}
+	t.byAccess[token.AccessToken] = token
+	t.byRefresh[token.RefreshToken] = token
+	return *token
+}
+
+// check returns the user an access token acts as, or why it can't be used.
+func (t *tokens) check(accessToken string) (userId string, problem string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	token, ok := t.byAccess[accessToken]
+	switch {
+	case !ok && t.requireKnown:
+		return "", "You did not pass a valid OAuth access token."
+	case !ok:
+		return MeId, ""
+	case token.Revoked:
+		return "", "Access token has been revoked."
+	case !t.now().Before(token.ExpiresAt):
+		return "", "Access token has expired."
+	}
+	return token.UserId, ""
+}
+
+// IssueToken issues an access token and refresh token acting as a user.
+func (sb *Sandbox) IssueToken(userId string) Token {
+	sb.tokens.mu.Lock()
+	defer sb.tokens.mu.Unlock()
+	return sb.tokens.issue(userId)
+}
+
+// ExpireToken makes an issued access token expire now. Its refresh token
+// still works.
+func (sb *Sandbox) ExpireToken(accessToken string) {
+	sb.tokens.mu.Lock()
+	defer sb.tokens.mu.Unlock()
+	if token, ok := sb.tokens.byAccess[accessToken]; ok {
+		token.ExpiresAt = sb.tokens.now()
+	}
+}
+
+// RevokeToken revokes an issued access token and its refresh token.
+func (sb *Sandbox) RevokeToken(accessToken string) {
+	sb.tokens.mu.Lock()
+	defer sb.tokens.mu.Unlock()
+	if token, ok := sb.tokens.byAccess[accessToken]; ok {
+		token.Revoked = true
+	}
+}
+
+// Tokens lists the tokens the sandbox has issued.
+func (sb *Sandbox) Tokens() (issued []Token) {
+	sb.tokens.mu.Lock()
+	defer sb.tokens.mu.Unlock()
+	for _, token := range sb.tokens.byAccess {
+		issued = append(issued, *token)
+	}
+	return
+}
+
+// authorize serves GET /oauth/authorize. The user approves at once and is
+// redirected to redirect_uri with a code. The sandbox-only user_id parameter
+// picks who approves; it defaults to MeId.
+func (sb *Sandbox) authorize(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sb.logger.Println("GET /oauth/authorize request:", r.URL.RawQuery)
+
+	query := r.URL.Query()
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if query.Get("client_id") == "" || query.Get("redirect_uri") == "" || err != nil {
+		sb.writeError(w, 400, codeInvalidRequest, "You must pass a client_id and redirect_uri.")
+		return
+	}
+	if sb.tokens.clientId != "" && query.Get("client_id") != sb.tokens.clientId {
+		sb.writeError(w, 400, codeInvalidRequest, "Invalid client_id.")
+		return
+	}
+
+	userId := query.Get("user_id")
+	if userId == "" {
+		userId = MeId
+	}
+	sb.graph.mu.Lock()
+	_, ok := sb.graph.users[userId]
+	sb.graph.mu.Unlock()
+	if !ok {
+		sb.writeError(w, 400, codeNotFound, "User not found.")
+		return
+	}
+
+	code := randomToken()
+	sb.tokens.mu.Lock()
+	sb.tokens.codes[code] = userId
+	sb.tokens.mu.Unlock()
+
+	params := redirect.Query()
+	params.Set("code", code)
+	if state := query.Get("state"); state != "" {
+		params.Set("state", state)
+	}
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// accessToken serves POST /oauth/access_token, which exchanges an
+// authorization code or a refresh token for a new access token. A refreshed
+// access token replaces the old one, which stops working.
+func (sb *Sandbox) accessToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sb.logger.Println("POST /oauth/access_token request")
+
+	if err := r.ParseForm(); err != nil {
+		sb.writeError(w, 400, codeInvalidRequest, "Could not parse request.")
+		return
+	}
+
+	t := sb.tokens
+	t.mu.Lock()
+	if t.clientId != "" && (r.PostForm.Get("client_id") != t.clientId || r.PostForm.Get("client_secret") != t.clientSecret) {
+		t.mu.Unlock()
+		sb.writeError(w, 400, codeInvalidRequest, "Invalid client_id or client_secret.")
+		return
+	}
+
+	var token Token
+	code, refresh := r.PostForm.Get("code"), r.PostForm.Get("refresh_token")
+	switch {
+	case code != "":
+		userId, ok := t.codes[code]
+		if !ok {
+			t.mu.Unlock()
+			sb.writeError(w, 400, codeInvalidRequest, "Invalid authorization code.")
+			return
+		}
+		delete(t.codes, code)
+		token = t.issue(userId)
+	case refresh != "":
+		old, ok := t.byRefresh[refresh]
+		if !ok || old.Revoked {
+			t.mu.Unlock()
+			sb.writeError(w, 400, codeInvalidRequest, "Invalid refresh token.")
+			return
+		}
+		old.Revoked = true
+		delete(t.byRefresh, refresh)
+		token = t.issue(old.UserId)
+	default:
+		t.mu.Unlock()
+		sb.writeError(w, 400, codeInvalidRequest, "You must pass a code or refresh_token.")
+		return
+	}
+	expiresIn := int64(t.lifetime / time.Second)
+	t.mu.Unlock()
+
+	sb.graph.mu.Lock()
+	user := sb.graph.users[token.UserId]
+	sb.graph.mu.Unlock()
+
+	response := map[string]interface{}{
+		"access_token":  token.AccessToken,
+		"refresh_token": token.RefreshToken,
+		"expires_in":    expiresIn,
+		"token_type":    "bearer",
+		"user":          user,
+	}
+	if sb.state != nil {
+		sb.state.mu.Lock()
+		if m, ok := sb.state.members[token.UserId]; ok {
+			response["balance"] = formatBalance(m.balance)
+		}
+		sb.state.mu.Unlock()
+	}
+	sb.writeJSON(w, response)
+}
+
+// revokeToken serves DELETE /oauth/access_token, revoking the access token
+// the request is made with.
+func (sb *Sandbox) revokeToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sb.logger.Println("DELETE /oauth/access_token request")
+
+	request := sb.parseRequest(w, r)
+	if request == nil {
+		return
+	}
+	sb.RevokeToken(request.AccessToken)
+	sb.writeData(w, map[string]interface{}{})
+}
@@ -0,0 +1,132 @@
+package venmotest
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestTokens(t *testing.T) {
+	t.Parallel()
+	server := NewServer(Stateful(), WithTokenLifetime(time.Hour))
+	defer server.Close()
+
+	var me struct {
+		Data struct {
+			User User
+		}
+	}
+	getJSON(t, server.URL+"/me?access_token=anything", &me)
+	if me.Data.User.Id != MeId {
+		t.Error("Unknown tokens should act as MeId:", me.Data.User.Id)
+	}
+
+	token := server.Sandbox.IssueToken(SandboxUserId)
+	if token.ExpiresAt.Before(time.Now().Add(59*time.Minute)) || token.ExpiresAt.After(time.Now().Add(time.Hour)) {
+		t.Error("Token should last an hour:", token.ExpiresAt)
+	}
+	getJSON(t, server.URL+"/me?access_token="+token.AccessToken, &me)
+	if me.Data.User.Id != SandboxUserId {
+		t.Error("Issued token should act as its user:", me.Data.User.Id)
+	}
+
+	var created testPaymentResponse
+	postJSON(t, server.URL+"/payments", url.Values{"access_token": {token.AccessToken}, "user_id": {MeId}, "amount": {"5"}}, &created)
+	if server.Sandbox.Balance(SandboxUserId) != DefaultBalance-5 || server.Sandbox.Balance(MeId) != DefaultBalance+5 {
+		t.Error("Payment should have been sent by the token's user")
+	}
+
+	server.Sandbox.ExpireToken(token.AccessToken)
+	var rejected errorResponse
+	resp := getJSON(t, server.URL+"/me?access_token="+token.AccessToken, &rejected)
+	if resp.StatusCode != 401 || rejected.Error.Code != codeInvalidToken || rejected.Error.Message != "Access token has expired." {
+		t.Error("Expired token should be rejected with 261:", resp.StatusCode, rejected)
+	}
+}
+
+func TestCannedMeActsAsTokenUser(t *testing.T) {
+	t.Parallel()
+	server := NewServer()
+	defer server.Close()
+
+	var me struct {
+		Data struct {
+			Balance string
+			User    userView
+		}
+	}
+	getJSON(t, server.URL+"/me?access_token=anything", &me)
+	if me.Data.User.Id != MeId || me.Data.Balance != "1.23" {
+		t.Error("Unknown tokens should get the canned user:", me.Data)
+	}
+
+	token := server.Sandbox.IssueToken(SandboxUserId)
+	getJSON(t, server.URL+"/me?access_token="+token.AccessToken, &me)
+	if me.Data.User.Id != SandboxUserId || me.Data.User.Username == "keith-brisson" || me.Data.Balance != formatBalance(DefaultBalance) {
+		t.Error("Issued token should get its own user from the graph:", me.Data)
+	}
+
+	var rejected errorResponse
+	token = server.Sandbox.IssueToken("nobody")
+	if resp := getJSON(t, server.URL+"/me?access_token="+token.AccessToken, &rejected); resp.StatusCode != 401 {
+		t.Error("Token for a user not in the graph should be rejected:", resp.StatusCode, rejected)
+	}
+}
+
+func TestRequireIssuedTokens(t *testing.T) {
+	t.Parallel()
+	server := NewServer(RequireIssuedTokens())
+	defer server.Close()
+
+	resp, _ := http.Get(server.URL + "/me?access_token=anything")
+	resp.Body.Close()
+	if resp.StatusCode != 401 {
+		t.Error("Unknown token should be rejected:", resp.StatusCode)
+	}
+
+	token := server.Sandbox.IssueToken(MeId)
+	resp, _ = http.Get(server.URL + "/me?access_token=" + token.AccessToken)
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Error("Issued token should be accepted:", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest("DELETE", server.URL+"/oauth/access_token?access_token="+token.AccessToken, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	resp, _ = http.Get(server.URL + "/me?access_token=" + token.AccessToken)
+	resp.Body.Close()
+	if resp.StatusCode != 401 || !server.Sandbox.Tokens()[0].Revoked {
+		t.Error("Revoked token should be rejected:", resp.StatusCode)
+	}
+}
+
+func TestAccessTokenRefresh(t *testing.T) {
+	t.Parallel()
+	server := NewServer()
+	defer server.Close()
+
+	token := server.Sandbox.IssueToken(MeId)
+	var refreshed struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+		User         User
+	}
+	postJSON(t, server.URL+"/oauth/access_token", url.Values{"client_id": {"1"}, "client_secret": {"s"}, "refresh_token": {token.RefreshToken}}, &refreshed)
+	if refreshed.AccessToken == "" || refreshed.AccessToken == token.AccessToken || refreshed.ExpiresIn != int64(DefaultTokenLifetime/time.Second) || refreshed.User.Id != MeId {
+		t.Errorf("Refresh should issue a new token: %+v", refreshed)
+	}
+
+	var rejected errorResponse
+	resp := postJSON(t, server.URL+"/oauth/access_token", url.Values{"refresh_token": {token.RefreshToken}}, &rejected)
+	if resp.StatusCode != 400 {
+		t.Error("Refresh token should only be used once:", resp.StatusCode)
+	}
+	resp, _ = http.Get(server.URL + "/me?access_token=" + token.AccessToken)
+	resp.Body.Close()
+	if resp.StatusCode != 401 {
+		t.Error("Old access token should stop working:", resp.StatusCode)
+	}
+}
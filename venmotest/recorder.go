@@ -0,0 +1,164 @@
+package venmotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// RecordedRequest is a request the sandbox received, as the client sent it.
+// Form holds the body's form fields; Query the URL's.
+type RecordedRequest struct {
+	Time   time.Time   `json:"time"`
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Query  url.Values  `json:"query"`
+	Form   url.Values  `json:"form"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body"`
+}
+
+// Param returns a form field, or the query parameter of that name if the
+// form does not have it, the same way the sandbox reads parameters.
+func (r RecordedRequest) Param(key string) string {
+	if values, ok := r.Form[key]; ok && len(values) > 0 {
+		return values[0]
+	}
+	return r.Query.Get(key)
+}
+
+// Has reports whether the request sent key in its form or query.
+func (r RecordedRequest) Has(key string) bool {
+	_, inForm := r.Form[key]
+	_, inQuery := r.Query[key]
+	return inForm || inQuery
+}
+
+type recorder struct {
+	mu       sync.Mutex
+	requests []RecordedRequest
+}
+
+// record copies what is needed from r and puts its body back so the
+// routes can still read it.
+func (rec *recorder) record(r *http.Request) {
+	recorded := RecordedRequest{
+		Time:   time.Now(),
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Form:   url.Values{},
+		Header: r.Header.Clone(),
+	}
+
+	if r.Body != nil {
+		body, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err == nil {
+			recorded.Body = string(body)
+			if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+				if form, err := url.ParseQuery(recorded.Body); err == nil {
+					recorded.Form = form
+				}
+			}
+		}
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.requests = append(rec.requests, recorded)
+}
+
+// Requests returns every request the sandbox received, oldest first, except
+// those to its admin endpoints.
+func (sb *Sandbox) Requests() []RecordedRequest {
+	sb.recorder.mu.Lock()
+	defer sb.recorder.mu.Unlock()
+	return append([]RecordedRequest{}, sb.recorder.requests...)
+}
+
+// RequestsTo returns the recorded requests with the given method and path.
+func (sb *Sandbox) RequestsTo(method, path string) (matched []RecordedRequest) {
+	for _, request := range sb.Requests() {
+		if request.Method == method && request.Path == path {
+			matched = append(matched, request)
+		}
+	}
+	return
+}
+
+// ClearRequests forgets the recorded requests.
+func (sb *Sandbox) ClearRequests() {
+	sb.recorder.mu.Lock()
+	defer sb.recorder.mu.Unlock()
+	sb.recorder.requests = nil
+}
+
+// RequestMatcher checks a recorded request.
+type RequestMatcher func(RecordedRequest) bool
+
+// WithParam matches requests that sent key with the given value.
+func WithParam(key, value string) RequestMatcher {
+	return func(r RecordedRequest) bool {
+		return r.Has(key) && r.Param(key) == value
+	}
+}
+
+// WithoutParam matches requests that did not send key at all.
+func WithoutParam(key string) RequestMatcher {
+	return func(r RecordedRequest) bool {
+		return !r.Has(key)
+	}
+}
+
+// AssertRequest fails t unless some recorded request with the given method
+// and path matches all of matchers.
+func (sb *Sandbox) AssertRequest(t testing.TB, method, path string, matchers ...RequestMatcher) {
+	t.Helper()
+
+	requests := sb.RequestsTo(method, path)
+	for _, request := range requests {
+		if matchesAll(request, matchers) {
+			return
+		}
+	}
+
+	sent := []string{}
+	for _, request := range requests {
+		sent = append(sent, "query "+request.Query.Encode()+" form "+request.Form.Encode())
+	}
+	t.Errorf("No matching %s %s request was sent. Received %d: %s", method, path, len(requests), strings.Join(sent, "; "))
+}
+
+// AssertPaymentPosted fails t unless a POST /payments request matching all
+// of matchers was sent.
+func (sb *Sandbox) AssertPaymentPosted(t testing.TB, matchers ...RequestMatcher) {
+	t.Helper()
+	sb.AssertRequest(t, "POST", "/payments", matchers...)
+}
+
+func matchesAll(request RecordedRequest, matchers []RequestMatcher) bool {
+	for _, matcher := range matchers {
+		if !matcher(request) {
+			return false
+		}
+	}
+	return true
+}
+
+// serveRequestsAdmin lists (GET) or clears (DELETE) the recorded requests.
+func (sb *Sandbox) serveRequestsAdmin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "DELETE" {
+		sb.ClearRequests()
+	}
+	json.NewEncoder(w).Encode(sb.Requests())
+}
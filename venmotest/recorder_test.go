@@ -0,0 +1,74 @@
+package venmotest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestRecordsRequests(t *testing.T) {
+	t.Parallel()
+	server := NewServer()
+	defer server.Close()
+
+	http.PostForm(server.URL+"/payments", url.Values{"access_token": {"token"}, "phone": {"15555555555"}, "amount": {"0.10"}, "audience": {"friends"}})
+	http.Get(server.URL + "/me?access_token=token")
+
+	requests := server.Sandbox.Requests()
+	if len(requests) != 2 {
+		t.Fatal("Both requests should have been recorded:", requests)
+	}
+	posted := requests[0]
+	if posted.Method != "POST" || posted.Path != "/payments" || posted.Form.Get("phone") != "15555555555" || posted.Param("access_token") != "token" {
+		t.Errorf("POST should have been recorded as sent: %+v", posted)
+	}
+	if requests[1].Param("access_token") != "token" || requests[1].Has("phone") {
+		t.Errorf("GET query should have been recorded: %+v", requests[1])
+	}
+
+	server.Sandbox.AssertPaymentPosted(t, WithParam("phone", "15555555555"), WithParam("audience", "friends"), WithoutParam("email"))
+
+	fake := &testing.T{}
+	server.Sandbox.AssertPaymentPosted(fake, WithParam("audience", "public"))
+	if !fake.Failed() {
+		t.Error("Assertion should fail when no request matches")
+	}
+}
+
+func TestRecordedRequestStillServed(t *testing.T) {
+	t.Parallel()
+	server := NewServer(Stateful())
+	defer server.Close()
+
+	resp, err := http.PostForm(server.URL+"/payments", url.Values{"access_token": {"token"}, "user_id": {SandboxUserId}, "amount": {"5"}})
+	if err != nil {
+		t.Fatal("POST should not have errored:", err)
+	}
+	resp.Body.Close()
+	if server.Sandbox.Balance(SandboxUserId) != DefaultBalance+5 {
+		t.Error("Recording should not consume the body:", server.Sandbox.Balance(SandboxUserId))
+	}
+}
+
+func TestRequestsAdmin(t *testing.T) {
+	t.Parallel()
+	server := NewServer()
+	defer server.Close()
+
+	http.Get(server.URL + "/me?access_token=token")
+
+	var listed []RecordedRequest
+	resp := getJSON(t, server.URL+"/_sandbox/requests", &listed)
+	if resp.StatusCode != 200 || len(listed) != 1 || listed[0].Path != "/me" {
+		t.Errorf("Admin endpoint should list requests but not itself: %+v", listed)
+	}
+
+	req, _ := http.NewRequest("DELETE", server.URL+"/_sandbox/requests", nil)
+	resp, _ = http.DefaultClient.Do(req)
+	json.NewDecoder(resp.Body).Decode(&listed)
+	resp.Body.Close()
+	if len(listed) != 0 || len(server.Sandbox.Requests()) != 0 {
+		t.Error("Requests should have been cleared:", listed)
+	}
+}
@@ -0,0 +1,403 @@
+// Package venmotest emulates the Venmo sandbox API for posting payments and charges,
+// listing payments, fetching payment 1111111111111111111, fetching the current user,
+// looking up users and their friends, and OAuth. Other requests get a Venmo-style 404 error, or are
+// proxied to the real Venmo sandbox if the sandbox was created WithProxy.
+//
+// Use NewServer in tests:
+//
+//	server := venmotest.NewServer()
+//	defer server.Close()
+//	account := &govenmo.Account{AccessToken: "faketoken", APIRoot: server.URL}
+//
+// The local_sandbox command serves the same emulator on port 4000.
+package venmotest
+
+import (
+	"embed"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/schema"
+)
+
+//go:embed responses
+var responses embed.FS
+
+type sandboxRequest struct {
+	AccessToken string `schema:"access_token"`
+	UserId      string `schema:"user_id"`
+	Email       string `schema:"email"`
+	Phone       string `schema:"phone"`
+	Amount      string `schema:"amount"`
+	Note        string `schema:"note"`
+	Audience    string `schema:"audience"`
+	Action      string `schema:"action"`
+
+	// actorId is the user the access token acts as.
+	actorId string
+}
+
+type venmoError struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+type errorResponse struct {
+	Error venmoError `json:"error"`
+}
+
+// Sandbox is an http.Handler emulating the Venmo API. Its routes are at the
+// root, so a client should use the server's URL as its API root.
+type Sandbox struct {
+	router   *mux.Router
+	decoder  *schema.Decoder
+	logger   *log.Logger
+	state    *state
+	pageSize int
+	graph    *graph
+	// initialGraph is the graph as created, which Reset goes back to.
+	initialGraph *graph
+	proxyURL     *url.URL
+	faults       faults
+	recorder     recorder
+	scenarios    []*scenario
+	tokens       *tokens
+	webhooks     *webhooks
+
+	settlementDelay time.Duration
+}
+
+// adminPrefix is where the sandbox's own control endpoints live. Requests to
+// them are not recorded and never get faults or scenarios.
+const adminPrefix = "/_sandbox/"
+
+// Option configures a Sandbox.
+type Option func(*Sandbox)
+
+// WithLogger makes the sandbox log each request. By default it is silent.
+func WithLogger(logger *log.Logger) Option {
+	return func(sb *Sandbox) {
+		sb.logger = logger
+	}
+}
+
+// RealSandboxURL is the Venmo sandbox API that WithProxy forwards to by default.
+const RealSandboxURL = "https://sandbox-api.venmo.com/v1/"
+
+// WithProxy forwards requests for routes the sandbox does not emulate to
+// target, or to RealSandboxURL if target is empty. Access tokens in those
+// requests leave the machine. Without this option they get a 404 error.
+func WithProxy(target string) Option {
+	return func(sb *Sandbox) {
+		if target == "" {
+			target = RealSandboxURL
+		}
+		proxyURL, err := url.Parse(target)
+		if err != nil {
+			panic("venmotest: invalid proxy URL: " + err.Error())
+		}
+		sb.proxyURL = proxyURL
+	}
+}
+
+// NewSandbox creates a Sandbox.
+func NewSandbox(options ...Option) *Sandbox {
+	sb := &Sandbox{
+		decoder:  schema.NewDecoder(),
+		logger:   log.New(ioutil.Discard, "", 0),
+		graph:    defaultGraph(),
+		tokens:   newTokens(),
+		webhooks: newWebhooks(),
+	}
+	sb.decoder.IgnoreUnknownKeys(false)
+
+	for _, option := range options {
+		option(sb)
+	}
+
+	if sb.state != nil {
+		for _, id := range sb.graph.order {
+			sb.state.addUser(sb.graph.users[id], DefaultBalance)
+		}
+	}
+	sb.initialGraph = sb.graph.clone()
+
+	r := mux.NewRouter()
+	r.HandleFunc("/payments", sb.paymentsIndex).Methods("POST")
+	r.HandleFunc("/payments", sb.listPayments).Methods("GET")
+	r.HandleFunc("/payments/{id}", sb.getPayment).Methods("GET")
+	r.HandleFunc("/me", sb.me).Methods("GET")
+	r.HandleFunc("/users/{id}", sb.getUser).Methods("GET")
+	r.HandleFunc("/users/{id}/friends", sb.listFriends).Methods("GET")
+	r.HandleFunc("/oauth/authorize", sb.authorize).Methods("GET")
+	r.HandleFunc("/oauth/access_token", sb.accessToken).Methods("POST")
+	r.HandleFunc("/oauth/access_token", sb.revokeToken).Methods("DELETE")
+	r.HandleFunc(adminPrefix+"faults", sb.serveFaultsAdmin).Methods("GET", "POST", "DELETE")
+	r.HandleFunc(adminPrefix+"requests", sb.serveRequestsAdmin).Methods("GET", "DELETE")
+	r.HandleFunc(adminPrefix+"webhooks", sb.serveWebhooksAdmin).Methods("GET", "POST", "DELETE")
+	r.HandleFunc(adminPrefix+"health", sb.serveHealth).Methods("GET")
+	r.HandleFunc(adminPrefix+"reset", sb.serveReset).Methods("POST")
+	r.HandleFunc(adminPrefix+"seed", sb.serveSeed).Methods("POST")
+	if sb.state != nil {
+		r.HandleFunc("/payments/{id}", sb.completePayment).Methods("PUT")
+	}
+
+	if sb.proxyURL != nil {
+		r.PathPrefix("/").HandlerFunc(sb.proxy)
+	} else {
+		r.NotFoundHandler = http.HandlerFunc(sb.notEmulated)
+		r.MethodNotAllowedHandler = http.HandlerFunc(sb.notEmulated)
+	}
+	sb.router = r
+
+	return sb
+}
+
+func (sb *Sandbox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, adminPrefix) {
+		sb.router.ServeHTTP(w, r)
+		return
+	}
+
+	sb.recorder.record(r)
+	if fault := sb.faults.match(r); fault != nil {
+		sb.serveWithFault(fault, w, r)
+		return
+	}
+	sb.serve(w, r)
+}
+
+// serve answers r with a matching scenario, or else the sandbox's routes.
+func (sb *Sandbox) serve(w http.ResponseWriter, r *http.Request) {
+	if s, form := sb.matchScenario(r); s != nil {
+		sb.serveScenario(s, form, w, r)
+		return
+	}
+	sb.router.ServeHTTP(w, r)
+}
+
+func sandboxUser(request *sandboxRequest) bool {
+	return request.UserId == "145434160922624933" || strings.ToLower(request.Email) == "venmo@venmo.com" || request.Phone == "15555555555"
+}
+
+// Routes lists the emulated routes as "METHOD /path" strings, e.g. "GET /me".
+func (sb *Sandbox) Routes() (routes []string) {
+	sb.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// The proxy's catch-all route.
+			return nil
+		}
+		for _, method := range methods {
+			routes = append(routes, method+" "+path)
+		}
+		return nil
+	})
+	return
+}
+
+func (sb *Sandbox) proxy(w http.ResponseWriter, r *http.Request) {
+	sb.logger.Println("Proxying to", sb.proxyURL.Host+":", r.URL.Path)
+	proxy := httputil.NewSingleHostReverseProxy(sb.proxyURL)
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		req.Host = sb.proxyURL.Host
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+func (sb *Sandbox) notEmulated(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	sb.logger.Println("Not emulated:", r.Method, r.URL.Path)
+	sb.writeError(w, 404, codeNotFound, "Resource not found. "+r.Method+" "+r.URL.Path+" is not emulated by the sandbox.")
+}
+
+func (sb *Sandbox) writeError(w http.ResponseWriter, status, code int, message string) {
+	b, err := json.Marshal(errorResponse{venmoError{Message: message, Code: code}})
+	if err != nil {
+		http.Error(w, "Sandbox JSON error", 500)
+		return
+	}
+	sb.logger.Println("Returning error:", string(b))
+	http.Error(w, string(b), status)
+}
+
+func (sb *Sandbox) writeFile(w http.ResponseWriter, sourceFile string) {
+	file, err := responses.Open(sourceFile)
+	if err != nil {
+		http.Error(w, "SANDBOX ERROR", 500)
+		sb.logger.Println("Sandbox error:", err)
+		return
+	}
+	defer file.Close()
+	io.Copy(w, file)
+}
+
+// parseRequest decodes the form and checks the access token, noting the user
+// it acts as. It writes an error response and returns nil if the request
+// should not be served.
+func (sb *Sandbox) parseRequest(w http.ResponseWriter, r *http.Request) *sandboxRequest {
+	err := r.ParseForm()
+
+	if err != nil {
+		sb.logger.Println("Error parsing request:", err)
+		http.Error(w, "Error parsing request.", 500)
+		return nil
+	}
+
+	var request *sandboxRequest = &sandboxRequest{}
+	err = sb.decoder.Decode(request, r.PostForm)
+	if err != nil {
+		http.Error(w, "Sandbox error. Could not decode request: "+err.Error(), 500)
+		return nil
+	}
+	sb.logger.Printf("Incoming form: %+v \n", r.PostForm)
+
+	if r.FormValue("access_token") != "" {
+		request.AccessToken = r.FormValue("access_token")
+	}
+
+	if request.AccessToken == "" {
+		sb.logger.Println("Missing access token.")
+		sb.writeError(w, 401, codeInvalidToken, "You did not pass a valid OAuth access token.")
+		return nil
+	}
+
+	actorId, problem := sb.tokens.check(request.AccessToken)
+	if problem != "" {
+		sb.logger.Println("Rejected access token:", problem)
+		sb.writeError(w, 401, codeInvalidToken, problem)
+		return nil
+	}
+	request.actorId = actorId
+
+	return request
+}
+
+func (sb *Sandbox) paymentsIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	request := sb.parseRequest(w, r)
+	if request == nil {
+		return
+	}
+
+	if sb.state != nil {
+		sb.statefulCreatePayment(w, request)
+		return
+	}
+
+	sourceFile := ""
+	amount, _ := strconv.ParseFloat(request.Amount, 64)
+	switch amount {
+	case 0.10:
+		sb.logger.Println("0.10 settled payment")
+		sourceFile = "responses/payment/settled.json"
+		if !sandboxUser(request) {
+			sourceFile = "responses/regular_user_error.json"
+			w.WriteHeader(400)
+		}
+	case 0.20:
+		sb.logger.Println("0.20 failed payment")
+		sourceFile = "responses/payment/failed.json"
+		if !sandboxUser(request) {
+			sourceFile = "responses/regular_user_error.json"
+			w.WriteHeader(400)
+		}
+	case 0.30:
+		sb.logger.Println("0.30 pending payment")
+		sourceFile = "responses/payment/pending.json"
+		if sandboxUser(request) {
+			sourceFile = "responses/payment/pending_error.json"
+			w.WriteHeader(400)
+		}
+	case -0.10:
+		sb.logger.Println("-0.10 settled charge")
+		sourceFile = "responses/payment/settled_charge.json"
+	case -0.20:
+		sb.logger.Println("-0.20 pending charge")
+		sourceFile = "responses/payment/pending_charge.json"
+		if sandboxUser(request) {
+			sourceFile = "responses/payment/pending_error.json"
+			w.WriteHeader(400)
+		}
+	default:
+		sb.logger.Println("Invalid amount:", request.Amount)
+		sourceFile = "responses/invalid_amount.json"
+		w.WriteHeader(400)
+	}
+
+	sb.writeFile(w, sourceFile)
+}
+
+func (sb *Sandbox) me(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	sb.logger.Println("/me request")
+
+	request := sb.parseRequest(w, r)
+	if request == nil {
+		return
+	}
+
+	if sb.state != nil {
+		sb.statefulMe(w, request)
+		return
+	}
+
+	if request.actorId == MeId {
+		sb.writeFile(w, "responses/users/me.json")
+		return
+	}
+
+	// Tokens issued for other users get their user from the social graph,
+	// with the balance every user starts with.
+	g := sb.graph
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.users[request.actorId]; !ok {
+		sb.writeError(w, 401, codeInvalidToken, "You did not pass a valid OAuth access token.")
+		return
+	}
+	sb.writeData(w, map[string]interface{}{
+		"balance": formatBalance(DefaultBalance),
+		"user":    g.view(request.actorId, request.actorId),
+	})
+}
+
+func (sb *Sandbox) getPayment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+	sb.logger.Println("GET /payments/" + id + " request")
+
+	if sb.parseRequest(w, r) == nil {
+		return
+	}
+
+	if sb.state != nil {
+		sb.statefulGetPayment(w, id)
+		return
+	}
+
+	if id != "1111111111111111111" {
+		sb.writeError(w, 404, codeNotFound, "Resource not found.")
+		return
+	}
+
+	sb.writeFile(w, "responses/payment/get.json")
+}
@@ -0,0 +1,124 @@
+package venmotest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+type testPaymentResponse struct {
+	Data struct {
+		Id      string
+		Status  string
+		Payment struct {
+			Id     string
+			Status string
+		}
+	}
+	Error venmoError
+}
+
+func getJSON(t *testing.T, rawurl string, parsed interface{}) *http.Response {
+	resp, err := http.Get(rawurl)
+	if err != nil {
+		t.Fatal("GET should not have errored:", err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(parsed); err != nil {
+		t.Fatal("Response should have been JSON:", err)
+	}
+	return resp
+}
+
+func postJSON(t *testing.T, rawurl string, form url.Values, parsed interface{}) *http.Response {
+	resp, err := http.PostForm(rawurl, form)
+	if err != nil {
+		t.Fatal("POST should not have errored:", err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(parsed); err != nil {
+		t.Fatal("Response should have been JSON:", err)
+	}
+	return resp
+}
+
+func TestServerPayments(t *testing.T) {
+	t.Parallel()
+	server := NewServer()
+	defer server.Close()
+
+	var parsed testPaymentResponse
+	resp := postJSON(t, server.URL+"/payments", url.Values{"access_token": {"token"}, "email": {"venmo@venmo.com"}, "amount": {"0.10"}}, &parsed)
+	if resp.StatusCode != 200 || parsed.Data.Payment.Status != "settled" {
+		t.Errorf("0.10 to the sandbox user should settle: %d %+v", resp.StatusCode, parsed)
+	}
+
+	parsed = testPaymentResponse{}
+	resp = postJSON(t, server.URL+"/payments", url.Values{"access_token": {"token"}, "amount": {"0.09"}}, &parsed)
+	if resp.StatusCode != 400 || parsed.Error.Code != 503 {
+		t.Errorf("Invalid amount should error: %d %+v", resp.StatusCode, parsed)
+	}
+
+	parsed = testPaymentResponse{}
+	resp = postJSON(t, server.URL+"/payments", url.Values{"amount": {"0.10"}}, &parsed)
+	if resp.StatusCode != 401 || parsed.Error.Code != 261 {
+		t.Errorf("Missing token should error: %d %+v", resp.StatusCode, parsed)
+	}
+}
+
+func TestServerGetPayment(t *testing.T) {
+	t.Parallel()
+	server := NewServer()
+	defer server.Close()
+
+	var parsed testPaymentResponse
+	resp := getJSON(t, server.URL+"/payments/1111111111111111111?access_token=token", &parsed)
+	if resp.StatusCode != 200 || parsed.Data.Id != "1111111111111111111" {
+		t.Errorf("Known payment should be returned: %d %+v", resp.StatusCode, parsed)
+	}
+
+	parsed = testPaymentResponse{}
+	resp = getJSON(t, server.URL+"/payments/ddd?access_token=token", &parsed)
+	if resp.StatusCode != 404 || parsed.Error.Code != 283 {
+		t.Errorf("Unknown payment should error: %d %+v", resp.StatusCode, parsed)
+	}
+}
+
+func TestServerDoesNotProxyByDefault(t *testing.T) {
+	t.Parallel()
+	server := NewServer()
+	defer server.Close()
+
+	var parsed testPaymentResponse
+	resp := getJSON(t, server.URL+"/not/emulated?access_token=token", &parsed)
+	if resp.StatusCode != 404 || parsed.Error.Code != codeNotFound {
+		t.Errorf("Unmatched route should be a Venmo 404: %d %+v", resp.StatusCode, parsed)
+	}
+
+	routes := map[string]bool{}
+	for _, route := range server.Sandbox.Routes() {
+		routes[route] = true
+	}
+	if !routes["GET /me"] || !routes["POST /payments"] || routes["PUT /payments/{id}"] {
+		t.Error("Wrong routes listed:", server.Sandbox.Routes())
+	}
+}
+
+func TestServerProxy(t *testing.T) {
+	t.Parallel()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"id": "` + r.URL.Path + `"}}`))
+	}))
+	defer upstream.Close()
+
+	server := NewServer(WithProxy(upstream.URL + "/v1/"))
+	defer server.Close()
+
+	var parsed testPaymentResponse
+	resp := getJSON(t, server.URL+"/not/emulated?access_token=token", &parsed)
+	if resp.StatusCode != 200 || parsed.Data.Id != "/v1/not/emulated" {
+		t.Errorf("Unmatched route should be proxied: %d %+v", resp.StatusCode, parsed)
+	}
+}
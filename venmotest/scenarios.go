@@ -0,0 +1,298 @@
+package venmotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Scenario is a canned response for requests matching a rule. Scenarios are
+// checked in order before the sandbox's own routes, and the first match is
+// served instead of them.
+type Scenario struct {
+	Name     string           `json:"name" yaml:"name"`
+	Match    ScenarioMatch    `json:"match" yaml:"match"`
+	Response ScenarioResponse `json:"response" yaml:"response"`
+}
+
+// ScenarioMatch says which requests a Scenario answers. Path is a path.Match
+// pattern such as "/payments/*". Form fields are read from the body or the
+// query, and Token is the access_token. Empty fields match anything. The
+// amount field is compared as a number, so "0.42" matches the "0.420000"
+// that govenmo sends.
+type ScenarioMatch struct {
+	Method string            `json:"method" yaml:"method"`
+	Path   string            `json:"path" yaml:"path"`
+	Form   map[string]string `json:"form" yaml:"form"`
+	Token  string            `json:"token" yaml:"token"`
+}
+
+// ScenarioResponse is what a Scenario answers with. The body is Body, or the
+// contents of BodyFile, or Template rendered with text/template. Templates
+// see the request as .Method, .Path, .Token and .Form, .Param "name" for a
+// single parameter, and .Now in Venmo's time format. The json function
+// encodes a value as JSON, so {{json (.Param "note")}} is a safely quoted
+// string. Status defaults to 200 and the Content-Type to application/json.
+type ScenarioResponse struct {
+	Status   int               `json:"status" yaml:"status"`
+	Headers  map[string]string `json:"headers" yaml:"headers"`
+	Body     string            `json:"body" yaml:"body"`
+	BodyFile string            `json:"body_file" yaml:"body_file"`
+	Template string            `json:"template" yaml:"template"`
+	Delay    time.Duration     `json:"-" yaml:"delay"`
+}
+
+// UnmarshalJSON reads Delay as a duration string like "250ms".
+func (r *ScenarioResponse) UnmarshalJSON(data []byte) error {
+	type plain ScenarioResponse
+	var wire struct {
+		plain
+		Delay string `json:"delay"`
+	}
+	err := json.Unmarshal(data, &wire)
+	if err != nil {
+		return err
+	}
+	*r = ScenarioResponse(wire.plain)
+	if wire.Delay != "" {
+		r.Delay, err = time.ParseDuration(wire.Delay)
+	}
+	return err
+}
+
+// scenarioRequest is what response templates see.
+type scenarioRequest struct {
+	Method string
+	Path   string
+	Token  string
+	Form   url.Values
+	Now    string
+}
+
+func (r scenarioRequest) Param(key string) string {
+	return r.Form.Get(key)
+}
+
+// scenarioFuncs are the functions response templates can call.
+var scenarioFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+type scenario struct {
+	Scenario
+	body     []byte
+	template *template.Template
+}
+
+// LoadScenarios reads the scenarios in every .yaml, .yml and .json file in
+// dir. Each file holds a list of scenarios. Files are read in name order, so
+// prefixes like 01- control which scenarios are checked first. A BodyFile is
+// relative to dir.
+func LoadScenarios(dir string) (scenarios []Scenario, err error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	var names []string
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".json":
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		var loaded []Scenario
+		if strings.ToLower(filepath.Ext(name)) == ".json" {
+			err = json.Unmarshal(data, &loaded)
+		} else {
+			err = yaml.UnmarshalStrict(data, &loaded)
+		}
+		if err != nil {
+			return nil, errors.New("Could not parse scenarios in " + name + ": " + err.Error())
+		}
+
+		for i := range loaded {
+			if loaded[i].Response.BodyFile != "" && !filepath.IsAbs(loaded[i].Response.BodyFile) {
+				loaded[i].Response.BodyFile = filepath.Join(dir, loaded[i].Response.BodyFile)
+			}
+		}
+		scenarios = append(scenarios, loaded...)
+	}
+	return
+}
+
+// WithScenarios makes the sandbox answer matching requests with scenarios. It
+// panics if a scenario's body file can't be read or its template can't be parsed.
+func WithScenarios(scenarios ...Scenario) Option {
+	return func(sb *Sandbox) {
+		for _, s := range scenarios {
+			compiled, err := compileScenario(s)
+			if err != nil {
+				panic("venmotest: " + err.Error())
+			}
+			sb.scenarios = append(sb.scenarios, compiled)
+		}
+	}
+}
+
+// WithScenarioDir loads scenarios from dir with LoadScenarios. It panics if
+// they can't be loaded.
+func WithScenarioDir(dir string) Option {
+	scenarios, err := LoadScenarios(dir)
+	if err != nil {
+		panic("venmotest: " + err.Error())
+	}
+	return WithScenarios(scenarios...)
+}
+
+func compileScenario(s Scenario) (compiled *scenario, err error) {
+	compiled = &scenario{Scenario: s, body: []byte(s.Response.Body)}
+	if s.Response.BodyFile != "" {
+		compiled.body, err = ioutil.ReadFile(s.Response.BodyFile)
+		if err != nil {
+			return nil, errors.New("Could not read body of scenario " + s.Name + ": " + err.Error())
+		}
+	}
+	if s.Response.Template != "" {
+		compiled.template, err = template.New(s.Name).Funcs(scenarioFuncs).Parse(s.Response.Template)
+		if err != nil {
+			return nil, errors.New("Could not parse template of scenario " + s.Name + ": " + err.Error())
+		}
+	}
+	return
+}
+
+func (s *scenario) matches(r *http.Request, form url.Values) bool {
+	m := s.Match
+	if m.Method != "" && !strings.EqualFold(m.Method, r.Method) {
+		return false
+	}
+	if m.Path != "" {
+		if matched, _ := path.Match(m.Path, r.URL.Path); !matched {
+			return false
+		}
+	}
+	if m.Token != "" && form.Get("access_token") != m.Token {
+		return false
+	}
+	for key, value := range m.Form {
+		if !formValueMatches(key, value, form.Get(key)) {
+			return false
+		}
+	}
+	return true
+}
+
+// formValueMatches reports whether a form field's value matches the value a
+// scenario wants. Amounts are compared as numbers.
+func formValueMatches(key, want, got string) bool {
+	if got == want {
+		return true
+	}
+	if key != "amount" {
+		return false
+	}
+	wantAmount, err := strconv.ParseFloat(want, 64)
+	if err != nil {
+		return false
+	}
+	gotAmount, err := strconv.ParseFloat(got, 64)
+	return err == nil && gotAmount == wantAmount
+}
+
+// requestForm returns the body's form fields followed by the query's, like
+// http.Request.Form, but puts the body back so routes and the proxy can
+// still read it.
+func requestForm(r *http.Request) url.Values {
+	form := url.Values{}
+	if r.Body != nil && strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		body, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err == nil {
+			form, _ = url.ParseQuery(string(body))
+		}
+	}
+	for key, values := range r.URL.Query() {
+		form[key] = append(form[key], values...)
+	}
+	return form
+}
+
+// matchScenario returns the first scenario matching r, or nil.
+func (sb *Sandbox) matchScenario(r *http.Request) (*scenario, url.Values) {
+	if len(sb.scenarios) == 0 {
+		return nil, nil
+	}
+	form := requestForm(r)
+	for _, s := range sb.scenarios {
+		if s.matches(r, form) {
+			return s, form
+		}
+	}
+	return nil, nil
+}
+
+func (sb *Sandbox) serveScenario(s *scenario, form url.Values, w http.ResponseWriter, r *http.Request) {
+	sb.logger.Println("Serving scenario", s.Name, "for", r.Method, r.URL.Path)
+
+	if s.Response.Delay > 0 {
+		select {
+		case <-time.After(s.Response.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	body := s.body
+	if s.template != nil {
+		data := scenarioRequest{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Token:  form.Get("access_token"),
+			Form:   form,
+			Now:    time.Now().UTC().Format(venmoTimeFormat),
+		}
+		var rendered bytes.Buffer
+		if err := s.template.Execute(&rendered, data); err != nil {
+			http.Error(w, "SANDBOX ERROR", 500)
+			sb.logger.Println("Sandbox error rendering scenario", s.Name+":", err)
+			return
+		}
+		body = rendered.Bytes()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	for key, value := range s.Response.Headers {
+		w.Header().Set(key, value)
+	}
+	status := s.Response.Status
+	if status == 0 {
+		status = 200
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,98 @@
+package venmotest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadScenarios(t *testing.T) {
+	scenarios, err := LoadScenarios("testdata/scenarios")
+	if err != nil {
+		t.Fatal("Scenarios should have loaded:", err)
+	}
+	if len(scenarios) != 3 || scenarios[0].Name != "frozen account" || scenarios[2].Name != "expired token" {
+		t.Errorf("Scenarios should be loaded in file order: %+v", scenarios)
+	}
+	if scenarios[1].Response.Delay != 10*time.Millisecond || scenarios[0].Response.BodyFile != filepath.Join("testdata/scenarios", "bodies/frozen.json") {
+		t.Errorf("Delay and body file should have been read: %+v", scenarios)
+	}
+
+	dir := t.TempDir()
+	ioutil.WriteFile(filepath.Join(dir, "bad.yaml"), []byte("- name: typo\n  mach: {}\n"), os.ModePerm)
+	if _, err := LoadScenarios(dir); err == nil {
+		t.Error("Unknown keys should be an error")
+	}
+}
+
+func TestScenarios(t *testing.T) {
+	t.Parallel()
+	server := NewServer(WithScenarioDir("testdata/scenarios"))
+	defer server.Close()
+
+	post := func(values url.Values) (*http.Response, string) {
+		resp, err := http.PostForm(server.URL+"/payments", values)
+		if err != nil {
+			t.Fatal("POST should not have errored:", err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	resp, body := post(url.Values{"access_token": {"token"}, "email": {"venmo@venmo.com"}, "amount": {"0.42"}})
+	if resp.StatusCode != 400 || body != `{"error": {"message": "Your account is frozen.", "code": 1396}}`+"\n" {
+		t.Error("Body file should have been served:", resp.StatusCode, body)
+	}
+
+	var echoed struct {
+		Data struct {
+			Payment Payment
+		}
+	}
+	resp = postJSON(t, server.URL+"/payments", url.Values{"access_token": {"token"}, "email": {"venmo@venmo.com"}, "amount": {"1.5"}, "note": {"echo"}}, &echoed)
+	if resp.StatusCode != 200 || resp.Header.Get("X-Scenario") != "echo" || echoed.Data.Payment.Amount != 1.5 || echoed.Data.Payment.Note != "echo" {
+		t.Errorf("Template should have been rendered: %d %+v", resp.StatusCode, echoed)
+	}
+
+	resp, body = post(url.Values{"access_token": {"token"}, "email": {"venmo@venmo.com"}, "amount": {"0.420000"}})
+	if resp.StatusCode != 400 {
+		t.Error("Amount should have matched as a number:", resp.StatusCode, body)
+	}
+
+	resp, _ = http.Get(server.URL + "/me?access_token=expired")
+	resp.Body.Close()
+	if resp.StatusCode != 401 {
+		t.Error("Token scenario should have matched:", resp.StatusCode)
+	}
+
+	resp, body = post(url.Values{"access_token": {"token"}, "email": {"venmo@venmo.com"}, "amount": {"0.10"}})
+	if resp.StatusCode != 200 || len(server.Sandbox.RequestsTo("POST", "/payments")) != 4 {
+		t.Error("Unmatched requests should reach the routes:", resp.StatusCode, body)
+	}
+}
+
+func TestScenarioTemplateEscapesJSON(t *testing.T) {
+	t.Parallel()
+	server := NewServer(WithScenarios(Scenario{
+		Name:     "echo",
+		Match:    ScenarioMatch{Method: "POST", Path: "/payments"},
+		Response: ScenarioResponse{Template: `{"data": {"payment": {"note": {{json (.Param "note")}}, "status": "pending"}}}`},
+	}))
+	defer server.Close()
+
+	var echoed struct {
+		Data struct {
+			Payment Payment
+		}
+	}
+	note := "Say \"hi\"\\\n\", \"status\": \"settled"
+	resp := postJSON(t, server.URL+"/payments", url.Values{"access_token": {"token"}, "note": {note}}, &echoed)
+	if resp.StatusCode != 200 || echoed.Data.Payment.Note != note || echoed.Data.Payment.Status != "pending" {
+		t.Errorf("Note should have been escaped: %d %+v", resp.StatusCode, echoed)
+	}
+}
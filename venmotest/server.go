@@ -0,0 +1,38 @@
+package venmotest
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+)
+
+// Server is a Sandbox listening on a local loopback port. Each Server is
+// independent, so tests using separate servers can run in parallel as long
+// as each points its own govenmo.Account at its server with Account.APIRoot.
+type Server struct {
+	*httptest.Server
+	Sandbox *Sandbox
+
+	// CA signed the server's certificate. It is nil unless the server was
+	// started with NewTLSServer.
+	CA *CA
+	// ClientCertificate is the certificate server.Client() presents when
+	// the server requires one.
+	ClientCertificate *tls.Certificate
+}
+
+// Close shuts down the server, then closes its Sandbox so that no scheduled
+// settlement or webhook delivery outlives it.
+func (s *Server) Close() {
+	s.Server.Close()
+	s.Sandbox.Close()
+}
+
+// NewServer starts a Sandbox configured with options. The caller should call
+// Close when finished. Point the client at URL, e.g. account.APIRoot = server.URL.
+func NewServer(options ...Option) *Server {
+	sandbox := NewSandbox(options...)
+	return &Server{
+		Server:  httptest.NewServer(sandbox),
+		Sandbox: sandbox,
+	}
+}
@@ -0,0 +1,347 @@
+package venmotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// IDs of the users every stateful sandbox starts with. MeId is the user
+// access tokens act as; SandboxUserId is Venmo's sandbox test user.
+const (
+	MeId          = "123245678901232456789"
+	SandboxUserId = "145434160922624933"
+	SomeoneElseId = "987654321012324567890"
+)
+
+// DefaultBalance is the starting balance of users in a stateful sandbox.
+const DefaultBalance = 100.00
+
+// Error codes returned by the stateful sandbox. 261 and 283 are Venmo's;
+// the others are the emulator's own.
+const (
+	codeInvalidToken   = 261
+	codeNotFound       = 283
+	codeInvalidRequest = 1001
+	codeInvalidAction  = 1002
+)
+
+type member struct {
+	user    User
+	balance float64
+}
+
+type state struct {
+	mu           sync.Mutex
+	members      map[string]*member
+	memberOrder  []string
+	payments     map[string]*Payment
+	paymentOrder []string
+	nextId       int64
+	now          func() time.Time
+}
+
+// Stateful makes the sandbox keep users, balances and payments in memory
+// instead of returning canned responses for the magic sandbox amounts.
+// POST /payments creates payments and moves money, GET and PUT /payments/{id}
+// read and complete them, and GET /me shows the current balance.
+// Access tokens act as the user they were issued for, and any other token
+// acts as the user MeId.
+func Stateful() Option {
+	return func(sb *Sandbox) {
+		sb.state = newState()
+	}
+}
+
+func newState() *state {
+	return &state{
+		members:  map[string]*member{},
+		payments: map[string]*Payment{},
+		nextId:   2000000000000000000,
+		now:      time.Now,
+	}
+}
+
+func (s *state) addUser(user User, balance float64) {
+	if _, ok := s.members[user.Id]; !ok {
+		s.memberOrder = append(s.memberOrder, user.Id)
+	}
+	s.members[user.Id] = &member{user: user, balance: balance}
+}
+
+func (s *state) addPayment(payment Payment) *Payment {
+	if payment.Id == "" {
+		s.nextId++
+		payment.Id = strconv.FormatInt(s.nextId, 10)
+	}
+	if payment.DateCreated.IsZero() {
+		payment.DateCreated = s.now().UTC()
+	}
+	if _, ok := s.payments[payment.Id]; !ok {
+		s.paymentOrder = append(s.paymentOrder, payment.Id)
+	}
+	s.payments[payment.Id] = &payment
+	return &payment
+}
+
+func (s *state) findMember(userId, email, phone string) *member {
+	if userId != "" {
+		return s.members[userId]
+	}
+	for _, id := range s.memberOrder {
+		m := s.members[id]
+		if (email != "" && strings.EqualFold(m.user.Email, email)) || (phone != "" && m.user.Phone == phone) {
+			return m
+		}
+	}
+	return nil
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+func (sb *Sandbox) mustState() *state {
+	if sb.state == nil {
+		panic("venmotest: sandbox is not stateful, create it with the Stateful option")
+	}
+	return sb.state
+}
+
+// AddUser adds or replaces a user. The balance only matters in stateful mode.
+func (sb *Sandbox) AddUser(user User, balance float64) {
+	sb.graph.mu.Lock()
+	sb.graph.addUser(user)
+	sb.graph.mu.Unlock()
+
+	if s := sb.state; s != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.addUser(user, balance)
+	}
+}
+
+// Balance returns a user's balance in a stateful sandbox.
+func (sb *Sandbox) Balance(userId string) float64 {
+	s := sb.mustState()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m, ok := s.members[userId]; ok {
+		return m.balance
+	}
+	return 0
+}
+
+// AddPayment stores a payment in a stateful sandbox without moving any money.
+// A missing Id or DateCreated is filled in. It returns the stored payment.
+func (sb *Sandbox) AddPayment(payment Payment) Payment {
+	s := sb.mustState()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.addPayment(payment)
+}
+
+// Payment returns a payment stored in a stateful sandbox.
+func (sb *Sandbox) Payment(id string) (Payment, bool) {
+	s := sb.mustState()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	payment, ok := s.payments[id]
+	if !ok {
+		return Payment{}, false
+	}
+	return *payment, true
+}
+
+func (sb *Sandbox) writeData(w http.ResponseWriter, data interface{}) {
+	sb.writeJSON(w, map[string]interface{}{"data": data})
+}
+
+func (sb *Sandbox) writeJSON(w http.ResponseWriter, response interface{}) {
+	b, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "Sandbox JSON error", 500)
+		return
+	}
+	w.Write(b)
+}
+
+func formatBalance(balance float64) string {
+	return fmt.Sprintf("%.2f", balance)
+}
+
+func (sb *Sandbox) statefulCreatePayment(w http.ResponseWriter, request *sandboxRequest) {
+	s := sb.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	actor := s.members[request.actorId]
+	if actor == nil {
+		sb.writeError(w, 401, codeInvalidToken, "You did not pass a valid OAuth access token.")
+		return
+	}
+
+	amount, err := strconv.ParseFloat(request.Amount, 64)
+	amount = roundCents(amount)
+	if err != nil || amount == 0 || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		sb.writeError(w, 400, codeInvalidRequest, "Invalid amount.")
+		return
+	}
+
+	payment := Payment{
+		Status:   "pending",
+		Action:   "pay",
+		Actor:    actor.user,
+		Amount:   math.Abs(amount),
+		Audience: request.Audience,
+		Note:     request.Note,
+		Medium:   "api",
+	}
+	if payment.Audience == "" {
+		payment.Audience = "public"
+	}
+	if amount < 0 {
+		payment.Action = "charge"
+	}
+
+	target := s.findMember(request.UserId, request.Email, request.Phone)
+	switch {
+	case target != nil:
+		user := target.user
+		payment.Target = Target{Type: "user", User: &user}
+	case request.UserId != "":
+		sb.writeError(w, 400, codeNotFound, "User not found.")
+		return
+	case request.Email != "":
+		email := request.Email
+		payment.Target = Target{Type: "email", Email: &email}
+	case request.Phone != "":
+		phone := request.Phone
+		payment.Target = Target{Type: "phone", Phone: &phone}
+	default:
+		sb.writeError(w, 400, codeInvalidRequest, "You must specify a user_id, email or phone.")
+		return
+	}
+
+	if target == actor {
+		sb.writeError(w, 400, codeInvalidRequest, "You cannot pay or charge yourself.")
+		return
+	}
+
+	// Payments to Venmo users settle immediately, or after the settlement
+	// delay. Payments to people who are not on Venmo, and all charges, stay pending.
+	if payment.Action == "pay" && target != nil && sb.settlementDelay <= 0 {
+		now := s.now().UTC()
+		payment.Status = "settled"
+		payment.DateCompleted = &now
+		actor.balance = roundCents(actor.balance - payment.Amount)
+		target.balance = roundCents(target.balance + payment.Amount)
+	}
+
+	stored := s.addPayment(payment)
+	sb.logger.Println("Created payment", stored.Id, stored.Action, stored.Amount, stored.Status)
+	sb.sendWebhook("payment.created", *stored)
+	if payment.Action == "pay" && target != nil && sb.settlementDelay > 0 {
+		sb.scheduleSettlement(stored.Id)
+	}
+
+	sb.writeData(w, map[string]interface{}{
+		"balance": formatBalance(actor.balance),
+		"payment": stored,
+	})
+}
+
+func (sb *Sandbox) statefulGetPayment(w http.ResponseWriter, id string) {
+	s := sb.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payment, ok := s.payments[id]
+	if !ok {
+		sb.writeError(w, 404, codeNotFound, "Resource not found.")
+		return
+	}
+	sb.writeData(w, payment)
+}
+
+func (sb *Sandbox) completePayment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+	sb.logger.Println("PUT /payments/" + id + " request")
+
+	request := sb.parseRequest(w, r)
+	if request == nil {
+		return
+	}
+
+	s := sb.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payment, ok := s.payments[id]
+	if !ok {
+		sb.writeError(w, 404, codeNotFound, "Resource not found.")
+		return
+	}
+
+	user := s.members[request.actorId]
+	if user == nil {
+		sb.writeError(w, 401, codeInvalidToken, "You did not pass a valid OAuth access token.")
+		return
+	}
+	isActor := payment.Actor.Id == user.user.Id
+	isTarget := payment.Target.User != nil && payment.Target.User.Id == user.user.Id
+
+	if payment.Status != "pending" {
+		sb.writeError(w, 400, codeInvalidAction, "This payment is not pending.")
+		return
+	}
+
+	switch {
+	case request.Action == "approve" && payment.Action == "charge" && isTarget:
+		actor := s.members[payment.Actor.Id]
+		user.balance = roundCents(user.balance - payment.Amount)
+		if actor != nil {
+			actor.balance = roundCents(actor.balance + payment.Amount)
+		}
+		payment.Status = "settled"
+	case request.Action == "deny" && payment.Action == "charge" && isTarget:
+		payment.Status = "cancelled"
+	case request.Action == "cancel" && isActor:
+		payment.Status = "cancelled"
+	default:
+		sb.writeError(w, 400, codeInvalidAction, "You cannot "+request.Action+" this payment.")
+		return
+	}
+
+	now := s.now().UTC()
+	payment.DateCompleted = &now
+	sb.logger.Println("Completed payment", payment.Id, "with", request.Action, "now", payment.Status)
+	sb.sendWebhook("payment.updated", *payment)
+
+	sb.writeData(w, payment)
+}
+
+func (sb *Sandbox) statefulMe(w http.ResponseWriter, request *sandboxRequest) {
+	s := sb.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	me := s.members[request.actorId]
+	if me == nil {
+		sb.writeError(w, 401, codeInvalidToken, "You did not pass a valid OAuth access token.")
+		return
+	}
+	sb.writeData(w, map[string]interface{}{
+		"balance": formatBalance(me.balance),
+		"user":    me.user,
+	})
+}
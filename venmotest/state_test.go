@@ -0,0 +1,120 @@
+package venmotest
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func putForm(t *testing.T, rawurl string, form url.Values) *http.Response {
+	req, err := http.NewRequest("PUT", rawurl, strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal("Could not create PUT request:", err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal("PUT should not have errored:", err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestStatefulPay(t *testing.T) {
+	t.Parallel()
+	server := NewServer(Stateful())
+	defer server.Close()
+
+	var parsed struct {
+		Data struct {
+			Balance string
+			Payment struct {
+				Id     string
+				Status string
+				Amount float64
+			}
+		}
+	}
+	resp := postJSON(t, server.URL+"/payments", url.Values{"access_token": {"token"}, "user_id": {SandboxUserId}, "amount": {"12.34"}}, &parsed)
+	if resp.StatusCode != 200 || parsed.Data.Payment.Status != "settled" || parsed.Data.Balance != "87.66" {
+		t.Fatalf("Payment should have settled: %d %+v", resp.StatusCode, parsed)
+	}
+
+	first := parsed.Data.Payment.Id
+	postJSON(t, server.URL+"/payments", url.Values{"access_token": {"token"}, "email": {"venmo@venmo.com"}, "amount": {"1"}}, &parsed)
+	if parsed.Data.Payment.Id == first {
+		t.Error("Each payment should get a fresh ID")
+	}
+
+	if server.Sandbox.Balance(MeId) != 86.66 || server.Sandbox.Balance(SandboxUserId) != 113.34 {
+		t.Error("Balances should have moved:", server.Sandbox.Balance(MeId), server.Sandbox.Balance(SandboxUserId))
+	}
+
+	var me struct {
+		Data struct {
+			Balance string
+			User    User
+		}
+	}
+	getJSON(t, server.URL+"/me?access_token=token", &me)
+	if me.Data.Balance != "86.66" || me.Data.User.Id != MeId {
+		t.Errorf("GET /me should show the new balance: %+v", me)
+	}
+
+	var fetched testPaymentResponse
+	resp = getJSON(t, server.URL+"/payments/"+first+"?access_token=token", &fetched)
+	if resp.StatusCode != 200 || fetched.Data.Id != first || fetched.Data.Status != "settled" {
+		t.Errorf("Stored payment should be returned: %d %+v", resp.StatusCode, fetched)
+	}
+
+	parsed.Data.Payment.Id = ""
+	postJSON(t, server.URL+"/payments", url.Values{"access_token": {"token"}, "email": {"new@example.com"}, "amount": {"5"}}, &parsed)
+	if parsed.Data.Payment.Status != "pending" || server.Sandbox.Balance(MeId) != 86.66 {
+		t.Error("Payment to a non-user should stay pending and not move money:", parsed.Data.Payment.Status)
+	}
+}
+
+func TestStatefulCompletePayment(t *testing.T) {
+	t.Parallel()
+	server := NewServer(Stateful())
+	defer server.Close()
+
+	me := User{Id: MeId}
+	someoneElse := User{Id: SomeoneElseId}
+	incoming := server.Sandbox.AddPayment(Payment{Status: "pending", Action: "charge", Actor: someoneElse, Target: Target{Type: "user", User: &me}, Amount: 10})
+	denied := server.Sandbox.AddPayment(Payment{Status: "pending", Action: "charge", Actor: someoneElse, Target: Target{Type: "user", User: &me}, Amount: 10})
+	outgoing := server.Sandbox.AddPayment(Payment{Status: "pending", Action: "charge", Actor: me, Target: Target{Type: "user", User: &someoneElse}, Amount: 10})
+
+	complete := func(id, action string) int {
+		return putForm(t, server.URL+"/payments/"+id+"?access_token=token", url.Values{"action": {action}}).StatusCode
+	}
+
+	if status := complete(outgoing.Id, "approve"); status != 400 {
+		t.Error("Actor should not be able to approve their own charge:", status)
+	}
+	if status := complete(incoming.Id, "approve"); status != 200 {
+		t.Error("Target should be able to approve a charge:", status)
+	}
+	if status := complete(incoming.Id, "cancel"); status != 400 {
+		t.Error("Settled charge should not be cancellable:", status)
+	}
+	if status := complete(denied.Id, "deny"); status != 200 {
+		t.Error("Target should be able to deny a charge:", status)
+	}
+	if status := complete(outgoing.Id, "cancel"); status != 200 {
+		t.Error("Actor should be able to cancel a charge:", status)
+	}
+	if status := complete("missing", "cancel"); status != 404 {
+		t.Error("Unknown payment should not be found:", status)
+	}
+
+	for id, expected := range map[string]string{incoming.Id: "settled", denied.Id: "cancelled", outgoing.Id: "cancelled"} {
+		if payment, _ := server.Sandbox.Payment(id); payment.Status != expected || payment.DateCompleted == nil {
+			t.Errorf("Payment %s should be %s: %+v", id, expected, payment)
+		}
+	}
+	if server.Sandbox.Balance(MeId) != 90 || server.Sandbox.Balance(SomeoneElseId) != 110 {
+		t.Error("Approving should have moved money:", server.Sandbox.Balance(MeId), server.Sandbox.Balance(SomeoneElseId))
+	}
+}
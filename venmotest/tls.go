@@ -0,0 +1,181 @@
+package venmotest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// CA is a certificate authority generated for a sandbox. It signs the
+// server's certificate and, if they are required, client certificates.
+type CA struct {
+	Certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+// NewCA generates a CA valid for a day.
+func NewCA() (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template, err := certificateTemplate("venmotest CA")
+	if err != nil {
+		return nil, err
+	}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{Certificate: certificate, key: key}, nil
+}
+
+func certificateTemplate(name string) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name, Organization: []string{"govenmo venmotest"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}, nil
+}
+
+// CertPool returns a pool trusting only the CA.
+func (ca *CA) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Certificate)
+	return pool
+}
+
+// PEM returns the CA's certificate in PEM format, for clients configured
+// with a file.
+func (ca *CA) PEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Certificate.Raw})
+}
+
+// ServerCertificate issues a certificate for hosts, which may be names or IP
+// addresses.
+func (ca *CA) ServerCertificate(hosts ...string) (tls.Certificate, error) {
+	template, err := certificateTemplate(hosts[0])
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	return ca.issue(template)
+}
+
+// ClientCertificate issues a client certificate for name.
+func (ca *CA) ClientCertificate(name string) (tls.Certificate, error) {
+	template, err := certificateTemplate(name)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	return ca.issue(template)
+}
+
+func (ca *CA) issue(template *x509.Certificate) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Certificate, &key.PublicKey, ca.key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der, ca.Certificate.Raw}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// ServerTLSConfig is a server configuration presenting a certificate for
+// localhost, 127.0.0.1 and ::1. If requireClientCert is true, clients must
+// present a certificate signed by the CA.
+func (ca *CA) ServerTLSConfig(requireClientCert bool) (*tls.Config, error) {
+	certificate, err := ca.ServerCertificate("localhost", "127.0.0.1", "::1")
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12}
+	if requireClientCert {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		config.ClientCAs = ca.CertPool()
+	}
+	return config, nil
+}
+
+// TLSServerOptions configures NewTLSServer.
+type TLSServerOptions struct {
+	// RequireClientCert makes the server reject clients without a
+	// certificate signed by its CA.
+	RequireClientCert bool
+}
+
+// NewTLSServer starts a Sandbox configured with options over HTTPS, with a
+// freshly generated CA and server certificate. server.CA.CertPool() trusts
+// it, and server.Client() is already set up to use it, including a client
+// certificate if one is required:
+//
+//	account.HTTPClient = server.Client()
+func NewTLSServer(tlsOptions TLSServerOptions, options ...Option) *Server {
+	ca, err := NewCA()
+	if err != nil {
+		panic("venmotest: could not generate CA: " + err.Error())
+	}
+	config, err := ca.ServerTLSConfig(tlsOptions.RequireClientCert)
+	if err != nil {
+		panic("venmotest: could not generate server certificate: " + err.Error())
+	}
+
+	sandbox := NewSandbox(options...)
+	server := &Server{
+		Server:  httptest.NewUnstartedServer(sandbox),
+		Sandbox: sandbox,
+		CA:      ca,
+	}
+	server.TLS = config
+	server.StartTLS()
+
+	clientConfig := &tls.Config{RootCAs: ca.CertPool(), MinVersion: tls.VersionTLS12}
+	if tlsOptions.RequireClientCert {
+		certificate, err := ca.ClientCertificate("venmotest client")
+		if err != nil {
+			server.Close()
+			panic("venmotest: could not generate client certificate: " + err.Error())
+		}
+		server.ClientCertificate = &certificate
+		clientConfig.Certificates = []tls.Certificate{certificate}
+	}
+	server.Client().Transport = &http.Transport{TLSClientConfig: clientConfig}
+	return server
+}
@@ -0,0 +1,65 @@
+package venmotest
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestTLSServer(t *testing.T) {
+	t.Parallel()
+	server := NewTLSServer(TLSServerOptions{}, Stateful(), WithPageSize(1))
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "/me?access_token=token")
+	if err != nil {
+		t.Fatal("Client should trust the generated CA:", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 || resp.TLS == nil {
+		t.Error("Request should have been served over TLS:", resp.StatusCode)
+	}
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: server.CA.CertPool()}}}
+	resp, err = client.Get(server.URL + "/users/" + MeId + "/friends?access_token=token")
+	if err != nil {
+		t.Fatal("CA pool should be usable on its own:", err)
+	}
+	var page listResponse
+	json.NewDecoder(resp.Body).Decode(&page)
+	resp.Body.Close()
+	if page.Pagination.Next == "" || page.Pagination.Next[:8] != "https://" {
+		t.Error("Next links should use https:", page.Pagination.Next)
+	}
+
+	if _, err := http.Get(server.URL + "/me?access_token=token"); err == nil {
+		t.Error("Default client should not trust the sandbox")
+	}
+}
+
+func TestTLSServerClientCert(t *testing.T) {
+	t.Parallel()
+	server := NewTLSServer(TLSServerOptions{RequireClientCert: true})
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "/me?access_token=token")
+	if err != nil {
+		t.Fatal("Client with certificate should be accepted:", err)
+	}
+	resp.Body.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: server.CA.CertPool()}}}
+	if resp, err := client.Get(server.URL + "/me?access_token=token"); err == nil {
+		resp.Body.Close()
+		t.Error("Client without certificate should be rejected")
+	}
+
+	other, _ := NewCA()
+	stranger, _ := other.ClientCertificate("stranger")
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: server.CA.CertPool(), Certificates: []tls.Certificate{stranger}}}}
+	if resp, err := client.Get(server.URL + "/me?access_token=token"); err == nil {
+		resp.Body.Close()
+		t.Error("Client certificate from another CA should be rejected")
+	}
+}
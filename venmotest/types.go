@@ -0,0 +1,129 @@
+package venmotest
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// venmoTimeFormat is how the Venmo API writes times, always in UTC.
+const venmoTimeFormat = "2006-01-02T15:04:05.999999"
+
+// User is a Venmo user held by a stateful Sandbox, in Venmo's wire format.
+type User struct {
+	Id                string `json:"id"`
+	Username          string `json:"username"`
+	DisplayName       string `json:"display_name"`
+	FirstName         string `json:"first_name"`
+	LastName          string `json:"last_name"`
+	Email             string `json:"email,omitempty"`
+	Phone             string `json:"phone,omitempty"`
+	About             string `json:"about"`
+	ProfilePictureUrl string `json:"profile_picture_url"`
+	DateJoined        string `json:"date_joined,omitempty"`
+}
+
+// Target is who a Payment was sent to. User is nil when the payment went to
+// an email address or phone number that is not a Venmo user.
+type Target struct {
+	Type  string  `json:"type"`
+	Email *string `json:"email"`
+	Phone *string `json:"phone"`
+	User  *User   `json:"user"`
+}
+
+// Payment is a payment or charge held by a stateful Sandbox. Amount is always
+// positive; Action is "pay" or "charge".
+type Payment struct {
+	Id            string
+	Status        string
+	Action        string
+	Actor         User
+	Target        Target
+	Amount        float64
+	Audience      string
+	Note          string
+	Medium        string
+	DateCreated   time.Time
+	DateCompleted *time.Time
+}
+
+type wirePayment struct {
+	Id            string   `json:"id"`
+	Status        string   `json:"status"`
+	Action        string   `json:"action"`
+	Actor         User     `json:"actor"`
+	Target        Target   `json:"target"`
+	Amount        float64  `json:"amount"`
+	Audience      string   `json:"audience"`
+	Note          string   `json:"note"`
+	Medium        string   `json:"medium"`
+	DateCreated   string   `json:"date_created"`
+	DateCompleted *string  `json:"date_completed"`
+	Fee           *float64 `json:"fee"`
+	Refund        *string  `json:"refund"`
+}
+
+// MarshalJSON writes the payment the way the Venmo API does.
+func (p Payment) MarshalJSON() ([]byte, error) {
+	wire := wirePayment{
+		Id:          p.Id,
+		Status:      p.Status,
+		Action:      p.Action,
+		Actor:       p.Actor,
+		Target:      p.Target,
+		Amount:      p.Amount,
+		Audience:    p.Audience,
+		Note:        p.Note,
+		Medium:      p.Medium,
+		DateCreated: p.DateCreated.UTC().Format(venmoTimeFormat),
+	}
+	if p.DateCompleted != nil {
+		completed := p.DateCompleted.UTC().Format(venmoTimeFormat)
+		wire.DateCompleted = &completed
+	}
+	return json.Marshal(wire)
+}
+
+// UnmarshalJSON reads a payment in the Venmo API's format.
+func (p *Payment) UnmarshalJSON(data []byte) error {
+	var wire wirePayment
+	err := json.Unmarshal(data, &wire)
+	if err != nil {
+		return err
+	}
+
+	*p = Payment{
+		Id:       wire.Id,
+		Status:   wire.Status,
+		Action:   wire.Action,
+		Actor:    wire.Actor,
+		Target:   wire.Target,
+		Amount:   wire.Amount,
+		Audience: wire.Audience,
+		Note:     wire.Note,
+		Medium:   wire.Medium,
+	}
+	if wire.DateCreated != "" {
+		p.DateCreated, err = time.Parse(venmoTimeFormat, wire.DateCreated)
+		if err != nil {
+			return err
+		}
+	}
+	if wire.DateCompleted != nil {
+		completed, err := time.Parse(venmoTimeFormat, *wire.DateCompleted)
+		if err != nil {
+			return err
+		}
+		p.DateCompleted = &completed
+	}
+	return nil
+}
+
+// updated is when the payment last changed, which is what the after and
+// before filters of GET /payments compare against.
+func (p Payment) updated() time.Time {
+	if p.DateCompleted != nil && p.DateCompleted.After(p.DateCreated) {
+		return *p.DateCompleted
+	}
+	return p.DateCreated
+}
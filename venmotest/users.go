@@ -0,0 +1,164 @@
+package venmotest
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// graph is the sandbox's social graph: the users GET /users/{id} knows and
+// who is friends with whom. Friendships are mutual.
+type graph struct {
+	mu      sync.Mutex
+	users   map[string]User
+	order   []string
+	friends map[string][]string
+}
+
+// defaultUsers are the users every sandbox starts with.
+func defaultUsers() []User {
+	return []User{
+		{
+			Id: MeId, Username: "keith-brisson", DisplayName: "Keith Brisson", FirstName: "Keith", LastName: "Brisson",
+			Email: "email@example.com", Phone: "12345678900", About: " ", DateJoined: "2014-02-16T23:42:14",
+		},
+		{
+			Id: SandboxUserId, Username: "testuser", DisplayName: "Test User", FirstName: "Test", LastName: "User",
+			Email: "venmo@venmo.com", Phone: "15555555555", About: "Long walks on the beach, sunsets, testing", DateJoined: "2013-02-10T21:58:05",
+		},
+		{
+			Id: SomeoneElseId, Username: "someone-else", DisplayName: "Someone Else", FirstName: "Someone", LastName: "Else",
+			About: "No Short Bio", DateJoined: "2013-11-07T21:15:41",
+		},
+	}
+}
+
+// newGraph creates a graph of users. Each key of friends becomes friends with
+// every user listed for it.
+func newGraph(users []User, friends map[string][]string) *graph {
+	g := &graph{users: map[string]User{}, friends: map[string][]string{}}
+	for _, user := range users {
+		g.addUser(user)
+	}
+	for id, friendIds := range friends {
+		for _, friendId := range friendIds {
+			g.addFriendship(id, friendId)
+		}
+	}
+	return g
+}
+
+func defaultGraph() *graph {
+	return newGraph(defaultUsers(), map[string][]string{MeId: {SandboxUserId, SomeoneElseId}})
+}
+
+func (g *graph) addUser(user User) {
+	if _, ok := g.users[user.Id]; !ok {
+		g.order = append(g.order, user.Id)
+	}
+	g.users[user.Id] = user
+}
+
+func (g *graph) areFriends(a, b string) bool {
+	for _, id := range g.friends[a] {
+		if id == b {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *graph) addFriendship(a, b string) {
+	if a == b || g.areFriends(a, b) {
+		return
+	}
+	g.friends[a] = append(g.friends[a], b)
+	g.friends[b] = append(g.friends[b], a)
+}
+
+// userView is a user as seen by the requesting user.
+type userView struct {
+	User
+	FriendsCount int  `json:"friends_count"`
+	IsFriend     bool `json:"is_friend"`
+}
+
+func (g *graph) view(id, viewerId string) userView {
+	return userView{
+		User:         g.users[id],
+		FriendsCount: len(g.friends[id]),
+		IsFriend:     g.areFriends(viewerId, id),
+	}
+}
+
+// WithSocialGraph replaces the sandbox's users and friendships. Each key of
+// friends becomes friends with every user listed for it; friendships are mutual.
+// In stateful mode the users start with DefaultBalance.
+func WithSocialGraph(users []User, friends map[string][]string) Option {
+	return func(sb *Sandbox) {
+		sb.graph = newGraph(users, friends)
+	}
+}
+
+// AddFriendship makes two users friends.
+func (sb *Sandbox) AddFriendship(a, b string) {
+	sb.graph.mu.Lock()
+	defer sb.graph.mu.Unlock()
+	sb.graph.addFriendship(a, b)
+}
+
+func (sb *Sandbox) getUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+	sb.logger.Println("GET /users/" + id + " request")
+
+	request := sb.parseRequest(w, r)
+	if request == nil {
+		return
+	}
+	viewerId := request.actorId
+
+	g := sb.graph
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.users[id]; !ok {
+		sb.writeError(w, 404, codeNotFound, "Resource not found.")
+		return
+	}
+	sb.writeData(w, g.view(id, viewerId))
+}
+
+func (sb *Sandbox) listFriends(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := mux.Vars(r)["id"]
+	sb.logger.Println("GET /users/" + id + "/friends request")
+
+	request := sb.parseRequest(w, r)
+	if request == nil {
+		return
+	}
+	viewerId := request.actorId
+
+	g := sb.graph
+	g.mu.Lock()
+	if _, ok := g.users[id]; !ok {
+		g.mu.Unlock()
+		sb.writeError(w, 404, codeNotFound, "Resource not found.")
+		return
+	}
+
+	// Friends are listed in the order users were added, so pages are stable.
+	var friends []interface{}
+	for _, userId := range g.order {
+		if g.areFriends(id, userId) {
+			friends = append(friends, g.view(userId, viewerId))
+		}
+	}
+	g.mu.Unlock()
+
+	sb.writePage(w, r, friends)
+}
@@ -0,0 +1,47 @@
+package venmotest
+
+import (
+	"testing"
+)
+
+func TestUsers(t *testing.T) {
+	t.Parallel()
+	users := []User{{Id: "a", Username: "a"}, {Id: "b", Username: "b"}, {Id: "c", Username: "c"}, {Id: "d", Username: "d"}}
+	server := NewServer(WithSocialGraph(users, map[string][]string{"a": {"b", "c"}, "d": {"a"}}), WithPageSize(2))
+	defer server.Close()
+
+	var user struct {
+		Data struct {
+			Id           string
+			FriendsCount int `json:"friends_count"`
+		}
+	}
+	resp := getJSON(t, server.URL+"/users/a?access_token=token", &user)
+	if resp.StatusCode != 200 || user.Data.Id != "a" || user.Data.FriendsCount != 3 {
+		t.Errorf("User a should have three friends: %d %+v", resp.StatusCode, user)
+	}
+
+	var friends struct {
+		Pagination struct {
+			Next string
+		}
+		Data []User
+	}
+	getJSON(t, server.URL+"/users/a/friends?access_token=token", &friends)
+	if len(friends.Data) != 2 || friends.Data[0].Id != "b" || friends.Pagination.Next == "" {
+		t.Fatalf("First page should have two friends and a next link: %+v", friends)
+	}
+	next := friends.Pagination.Next
+	friends.Data = nil
+	friends.Pagination.Next = ""
+	getJSON(t, next+"&access_token=token", &friends)
+	if len(friends.Data) != 1 || friends.Data[0].Id != "d" || friends.Pagination.Next != "" {
+		t.Errorf("Second page should have the last friend: %+v", friends)
+	}
+
+	var failed testPaymentResponse
+	resp = getJSON(t, server.URL+"/users/missing/friends?access_token=token", &failed)
+	if resp.StatusCode != 404 || failed.Error.Code != codeNotFound {
+		t.Errorf("Unknown user should error: %d %+v", resp.StatusCode, failed)
+	}
+}
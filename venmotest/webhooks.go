@@ -0,0 +1,385 @@
+package venmotest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Defaults for retrying webhook deliveries that fail.
+const (
+	DefaultWebhookAttempts = 3
+	DefaultWebhookBackoff  = 500 * time.Millisecond
+)
+
+// WebhookDelivery is one attempt to deliver a webhook.
+type WebhookDelivery struct {
+	URL        string    `json:"url"`
+	Type       string    `json:"type"`
+	PaymentId  string    `json:"payment_id"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// Succeeded reports whether the callback answered with a 2xx status.
+func (d WebhookDelivery) Succeeded() bool {
+	return d.Error == "" && d.StatusCode >= 200 && d.StatusCode < 300
+}
+
+type webhookPayload struct {
+	DateCreated string  `json:"date_created"`
+	Type        string  `json:"type"`
+	Data        Payment `json:"data"`
+}
+
+type webhooks struct {
+	mu         sync.Mutex
+	urls       []string
+	deliveries []WebhookDelivery
+	attempts   int
+	backoff    time.Duration
+	client     *http.Client
+
+	// pending counts scheduled settlements and deliveries in progress, and
+	// idle is signalled when it drops to zero. timers holds the settlement
+	// timers that have not fired.
+	pending int
+	idle    *sync.Cond
+	timers  map[*time.Timer]bool
+
+	// generation goes up when the sandbox is reset. Settlements and
+	// deliveries from an earlier generation are dropped.
+	generation int
+
+	// ctx is cancelled when the sandbox is closed, which stops deliveries.
+	ctx    context.Context
+	cancel context.CancelFunc
+	closed bool
+}
+
+func newWebhooks() *webhooks {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &webhooks{
+		attempts: DefaultWebhookAttempts,
+		backoff:  DefaultWebhookBackoff,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		timers:   map[*time.Timer]bool{},
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	w.idle = sync.NewCond(&w.mu)
+	return w
+}
+
+// begin counts a new piece of background work. It returns false, and
+// counts nothing, once the sandbox is closed. The caller holds w.mu.
+func (w *webhooks) begin() bool {
+	if w.closed {
+		return false
+	}
+	w.pending++
+	return true
+}
+
+// done finishes a piece of background work. The caller holds w.mu.
+func (w *webhooks) done() {
+	w.pending--
+	if w.pending == 0 {
+		w.idle.Broadcast()
+	}
+}
+
+// stopTimers cancels every scheduled settlement. The caller holds w.mu.
+func (w *webhooks) stopTimers() {
+	for timer := range w.timers {
+		timer.Stop()
+		delete(w.timers, timer)
+		w.done()
+	}
+}
+
+// current reports whether work started in generation is still wanted.
+func (w *webhooks) current(generation int) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.generation == generation && !w.closed
+}
+
+// reset cancels scheduled settlements, drops deliveries in progress and
+// forgets past deliveries. Callback URLs are kept.
+func (w *webhooks) reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopTimers()
+	w.generation++
+	w.deliveries = nil
+}
+
+// WithWebhooks makes a stateful sandbox POST webhooks to urls. Payments that
+// are created send payment.created; payments that are approved, denied,
+// cancelled or settle send payment.updated.
+func WithWebhooks(urls ...string) Option {
+	return func(sb *Sandbox) {
+		for _, url := range urls {
+			sb.AddWebhook(url)
+		}
+	}
+}
+
+// WithWebhookRetries sets how many times a webhook is tried before giving up,
+// and how long to wait after the first failure. The wait doubles each time.
+func WithWebhookRetries(attempts int, backoff time.Duration) Option {
+	return func(sb *Sandbox) {
+		sb.webhooks.attempts = attempts
+		sb.webhooks.backoff = backoff
+	}
+}
+
+// WithSettlementDelay makes payments to Venmo users in a stateful sandbox
+// stay pending for delay before they settle and the money moves, instead of
+// settling at once.
+func WithSettlementDelay(delay time.Duration) Option {
+	return func(sb *Sandbox) {
+		sb.settlementDelay = delay
+	}
+}
+
+// AddWebhook adds a callback URL that webhooks are sent to.
+func (sb *Sandbox) AddWebhook(url string) {
+	sb.webhooks.mu.Lock()
+	defer sb.webhooks.mu.Unlock()
+	sb.webhooks.urls = append(sb.webhooks.urls, url)
+}
+
+// ClearWebhooks removes every callback URL and forgets past deliveries.
+func (sb *Sandbox) ClearWebhooks() {
+	sb.webhooks.mu.Lock()
+	defer sb.webhooks.mu.Unlock()
+	sb.webhooks.urls = nil
+	sb.webhooks.deliveries = nil
+}
+
+// WebhookDeliveries lists every attempt to deliver a webhook, oldest first.
+func (sb *Sandbox) WebhookDeliveries() []WebhookDelivery {
+	sb.webhooks.mu.Lock()
+	defer sb.webhooks.mu.Unlock()
+	return append([]WebhookDelivery{}, sb.webhooks.deliveries...)
+}
+
+// WaitForWebhooks waits until every scheduled settlement and webhook
+// delivery, including retries, has finished.
+func (sb *Sandbox) WaitForWebhooks() {
+	w := sb.webhooks
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for w.pending > 0 {
+		w.idle.Wait()
+	}
+}
+
+// Close cancels scheduled settlements and webhook deliveries, including
+// retries, and waits for those already running to return. Nothing is
+// scheduled or delivered afterwards. Server.Close calls it.
+func (sb *Sandbox) Close() {
+	w := sb.webhooks
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		w.cancel()
+		w.stopTimers()
+	}
+	w.mu.Unlock()
+	sb.WaitForWebhooks()
+}
+
+// sendWebhook delivers a webhook about payment to every callback URL in the
+// background. It may be called with the state locked.
+func (sb *Sandbox) sendWebhook(eventType string, payment Payment) {
+	w := sb.webhooks
+	w.mu.Lock()
+	urls := append([]string{}, w.urls...)
+	closed := w.closed
+	generation := w.generation
+	w.mu.Unlock()
+
+	if len(urls) == 0 || closed {
+		return
+	}
+
+	body, err := json.Marshal(webhookPayload{
+		DateCreated: time.Now().UTC().Format(venmoTimeFormat),
+		Type:        eventType,
+		Data:        payment,
+	})
+	if err != nil {
+		sb.logger.Println("Could not encode webhook:", err)
+		return
+	}
+
+	for _, url := range urls {
+		w.mu.Lock()
+		started := w.begin()
+		w.mu.Unlock()
+		if !started {
+			return
+		}
+		go func(url string) {
+			defer func() {
+				w.mu.Lock()
+				w.done()
+				w.mu.Unlock()
+			}()
+			sb.deliverWebhook(url, eventType, payment.Id, body, generation)
+		}(url)
+	}
+}
+
+// deliverWebhook POSTs body to url, retrying on failure, until it succeeds,
+// runs out of attempts, or the sandbox is reset or closed.
+func (sb *Sandbox) deliverWebhook(url, eventType, paymentId string, body []byte, generation int) {
+	w := sb.webhooks
+	backoff := w.backoff
+	attempts := w.attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if !w.current(generation) {
+			return
+		}
+		delivery := WebhookDelivery{URL: url, Type: eventType, PaymentId: paymentId, Attempt: attempt, Time: time.Now()}
+
+		req, err := http.NewRequestWithContext(w.ctx, "POST", url, bytes.NewReader(body))
+		var resp *http.Response
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+			resp, err = w.client.Do(req)
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if w.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			delivery.Error = err.Error()
+		} else {
+			delivery.StatusCode = resp.StatusCode
+		}
+		sb.logger.Printf("Webhook %s for payment %s to %s, attempt %d: %d %s\n", eventType, paymentId, url, attempt, delivery.StatusCode, delivery.Error)
+
+		w.mu.Lock()
+		if w.generation != generation {
+			w.mu.Unlock()
+			return
+		}
+		w.deliveries = append(w.deliveries, delivery)
+		w.mu.Unlock()
+
+		if delivery.Succeeded() || attempt == attempts {
+			return
+		}
+		select {
+		case <-time.After(backoff):
+		case <-w.ctx.Done():
+			return
+		}
+		backoff *= 2
+	}
+}
+
+// scheduleSettlement settles a pending payment to a Venmo user after the
+// sandbox's settlement delay, unless the sandbox is reset or closed first.
+func (sb *Sandbox) scheduleSettlement(id string) {
+	w := sb.webhooks
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.begin() {
+		return
+	}
+	generation := w.generation
+
+	// The timer is registered before its func can take w.mu, so the func
+	// finds it unless Reset or Close has already stopped it and counted it
+	// done.
+	var timer *time.Timer
+	timer = time.AfterFunc(sb.settlementDelay, func() {
+		w.mu.Lock()
+		scheduled := w.timers[timer]
+		delete(w.timers, timer)
+		w.mu.Unlock()
+		if !scheduled {
+			return
+		}
+
+		sb.settle(id, generation)
+		w.mu.Lock()
+		w.done()
+		w.mu.Unlock()
+	})
+	w.timers[timer] = true
+}
+
+// settle settles a pending payment to a Venmo user that was scheduled in
+// generation. Charges are never settled this way.
+func (sb *Sandbox) settle(id string, generation int) {
+	s := sb.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A reset since the settlement was scheduled may have reused its ID.
+	if !sb.webhooks.current(generation) {
+		return
+	}
+	payment, ok := s.payments[id]
+	if !ok || payment.Status != "pending" || payment.Action != "pay" || payment.Target.User == nil {
+		return
+	}
+	actor, target := s.members[payment.Actor.Id], s.members[payment.Target.User.Id]
+	if actor != nil {
+		actor.balance = roundCents(actor.balance - payment.Amount)
+	}
+	if target != nil {
+		target.balance = roundCents(target.balance + payment.Amount)
+	}
+
+	now := s.now().UTC()
+	payment.Status = "settled"
+	payment.DateCompleted = &now
+	sb.logger.Println("Settled payment", payment.Id)
+	sb.sendWebhook("payment.updated", *payment)
+}
+
+// serveWebhooksAdmin lists (GET) the callback URLs and deliveries, adds (POST,
+// {"url": ...}) a callback URL, or clears (DELETE) both.
+func (sb *Sandbox) serveWebhooksAdmin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case "POST":
+		var added struct {
+			URL string `json:"url"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&added); err != nil || added.URL == "" {
+			sb.writeError(w, 400, codeInvalidRequest, "You must pass a url.")
+			return
+		}
+		sb.AddWebhook(added.URL)
+	case "DELETE":
+		sb.ClearWebhooks()
+	}
+
+	sb.webhooks.mu.Lock()
+	urls := append([]string{}, sb.webhooks.urls...)
+	sb.webhooks.mu.Unlock()
+	sb.writeJSON(w, map[string]interface{}{
+		"urls":       urls,
+		"deliveries": sb.WebhookDeliveries(),
+	})
+}
@@ -0,0 +1,179 @@
+package venmotest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// callback is a webhook receiver that fails the first failures requests.
+type callback struct {
+	mu       sync.Mutex
+	failures int
+	payloads []webhookPayload
+}
+
+func (c *callback) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failures > 0 {
+		c.failures--
+		w.WriteHeader(503)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	var payload webhookPayload
+	json.Unmarshal(body, &payload)
+	c.payloads = append(c.payloads, payload)
+}
+
+func TestWebhooks(t *testing.T) {
+	t.Parallel()
+	receiver := &callback{failures: 1}
+	callbackServer := httptest.NewServer(receiver)
+	defer callbackServer.Close()
+
+	server := NewServer(Stateful(), WithWebhooks(callbackServer.URL), WithWebhookRetries(3, time.Millisecond), WithSettlementDelay(20*time.Millisecond))
+	defer server.Close()
+
+	var created testPaymentResponse
+	postJSON(t, server.URL+"/payments", url.Values{"access_token": {"token"}, "user_id": {SandboxUserId}, "amount": {"10"}}, &created)
+	if created.Data.Payment.Status != "pending" || server.Sandbox.Balance(SandboxUserId) != DefaultBalance {
+		t.Error("Payment should wait for the settlement delay:", created.Data.Payment.Status)
+	}
+	postJSON(t, server.URL+"/payments", url.Values{"access_token": {"token"}, "user_id": {SandboxUserId}, "amount": {"-5"}}, &created)
+	charge := created.Data.Payment.Id
+
+	server.Sandbox.WaitForWebhooks()
+	if server.Sandbox.Balance(SandboxUserId) != DefaultBalance+10 {
+		t.Error("Payment should have settled:", server.Sandbox.Balance(SandboxUserId))
+	}
+
+	if resp := putForm(t, server.URL+"/payments/"+charge, url.Values{"access_token": {"token"}, "action": {"cancel"}}); resp.StatusCode != 200 {
+		t.Fatal("Charge should have been cancelled:", resp.StatusCode)
+	}
+	server.Sandbox.WaitForWebhooks()
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	seen := map[string]int{}
+	for _, payload := range receiver.payloads {
+		seen[payload.Type+" "+payload.Data.Status]++
+	}
+	if len(receiver.payloads) != 4 || seen["payment.created pending"] != 2 || seen["payment.updated settled"] != 1 || seen["payment.updated cancelled"] != 1 {
+		t.Errorf("Every change should have been delivered once: %v", seen)
+	}
+
+	deliveries := server.Sandbox.WebhookDeliveries()
+	failed := 0
+	for _, delivery := range deliveries {
+		if !delivery.Succeeded() {
+			failed++
+		}
+	}
+	if len(deliveries) != 5 || failed != 1 {
+		t.Errorf("The failed delivery should have been retried: %+v", deliveries)
+	}
+}
+
+func TestWebhookGivesUp(t *testing.T) {
+	t.Parallel()
+	receiver := &callback{failures: 10}
+	callbackServer := httptest.NewServer(receiver)
+	defer callbackServer.Close()
+
+	server := NewServer(Stateful(), WithWebhookRetries(2, time.Millisecond))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/_sandbox/webhooks", "application/json", strings.NewReader(`{"url": "`+callbackServer.URL+`"}`))
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatal("Webhook should have been added:", err)
+	}
+	resp.Body.Close()
+
+	var created testPaymentResponse
+	postJSON(t, server.URL+"/payments", url.Values{"access_token": {"token"}, "user_id": {SandboxUserId}, "amount": {"10"}}, &created)
+	server.Sandbox.WaitForWebhooks()
+
+	var listed struct {
+		URLs       []string
+		Deliveries []WebhookDelivery
+	}
+	getJSON(t, server.URL+"/_sandbox/webhooks", &listed)
+	if len(listed.URLs) != 1 || len(listed.Deliveries) != 2 || listed.Deliveries[1].StatusCode != 503 || listed.Deliveries[1].Attempt != 2 {
+		t.Errorf("Delivery should have been tried twice: %+v", listed)
+	}
+}
+
+func TestCloseStopsSettlementsAndRetries(t *testing.T) {
+	t.Parallel()
+	receiver := &callback{failures: 10}
+	callbackServer := httptest.NewServer(receiver)
+	defer callbackServer.Close()
+
+	server := NewServer(Stateful(), WithWebhooks(callbackServer.URL), WithWebhookRetries(5, time.Hour), WithSettlementDelay(time.Hour))
+	var created testPaymentResponse
+	postJSON(t, server.URL+"/payments", url.Values{"access_token": {"token"}, "user_id": {SandboxUserId}, "amount": {"10"}}, &created)
+	for len(server.Sandbox.WebhookDeliveries()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		server.Close()
+		server.Sandbox.WaitForWebhooks()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close should not have waited for the settlement or the retry")
+	}
+
+	if payment, _ := server.Sandbox.Payment(created.Data.Payment.Id); payment.Status != "pending" || server.Sandbox.Balance(SandboxUserId) != DefaultBalance {
+		t.Error("Payment should not have settled after Close:", payment.Status)
+	}
+	if deliveries := server.Sandbox.WebhookDeliveries(); len(deliveries) != 1 {
+		t.Errorf("Delivery should not have been retried after Close: %+v", deliveries)
+	}
+}
+
+func TestResetCancelsSettlementsAndRetries(t *testing.T) {
+	t.Parallel()
+	receiver := &callback{failures: 100}
+	callbackServer := httptest.NewServer(receiver)
+	defer callbackServer.Close()
+
+	server := NewServer(Stateful(), WithWebhooks(callbackServer.URL), WithWebhookRetries(3, 20*time.Millisecond), WithSettlementDelay(50*time.Millisecond))
+	defer server.Close()
+
+	var paid, charged testPaymentResponse
+	postJSON(t, server.URL+"/payments", url.Values{"access_token": {"token"}, "user_id": {SandboxUserId}, "amount": {"5"}}, &paid)
+	for len(server.Sandbox.WebhookDeliveries()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	server.Sandbox.Reset()
+
+	postJSON(t, server.URL+"/payments", url.Values{"access_token": {"token"}, "user_id": {SomeoneElseId}, "amount": {"-7"}}, &charged)
+	if charged.Data.Payment.Id != paid.Data.Payment.Id {
+		t.Fatal("Reset should have reused the payment ID:", charged.Data.Payment.Id)
+	}
+	time.Sleep(100 * time.Millisecond)
+	server.Sandbox.WaitForWebhooks()
+
+	if charge, _ := server.Sandbox.Payment(charged.Data.Payment.Id); charge.Status != "pending" {
+		t.Error("Settlement from before the reset should not have settled the charge:", charge.Status)
+	}
+	if server.Sandbox.Balance(MeId) != DefaultBalance || server.Sandbox.Balance(SomeoneElseId) != DefaultBalance {
+		t.Error("No money should have moved:", server.Sandbox.Balance(MeId), server.Sandbox.Balance(SomeoneElseId))
+	}
+	if deliveries := server.Sandbox.WebhookDeliveries(); len(deliveries) != 3 {
+		t.Errorf("Only the charge's webhook should have been tried after the reset: %+v", deliveries)
+	}
+}
@@ -0,0 +1,122 @@
+package govenmo
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// DefaultWaitInterval is how long WaitForPayment first waits between polls.
+const DefaultWaitInterval = time.Second
+
+// DefaultWaitMaxInterval is the longest WaitForPayment waits between polls.
+const DefaultWaitMaxInterval = 30 * time.Second
+
+// DefaultWaitJitter is how much WaitForPayment varies each wait, as a
+// fraction of it, so that many waiters do not poll in step.
+const DefaultWaitJitter = 0.2
+
+// WaitOptions configures WaitForPayment. The zero value uses the defaults.
+type WaitOptions struct {
+	// Interval is the first wait between polls. Each wait doubles, up to
+	// MaxInterval.
+	Interval    time.Duration
+	MaxInterval time.Duration
+
+	// Jitter varies each wait by up to this fraction of it. Zero uses
+	// DefaultWaitJitter and a negative value disables jitter.
+	Jitter float64
+
+	// Until, if set, stops the wait as soon as it returns true, even while
+	// the payment is still pending.
+	Until func(payment Payment) bool
+
+	// OnStatusChange, if set, is called whenever a poll finds the status
+	// changed since the previous poll.
+	OnStatusChange func(payment Payment, from string)
+}
+
+// IsTerminalStatus reports whether a payment with status will not change
+// status again.
+func IsTerminalStatus(status string) bool {
+	switch status {
+	case "settled", "cancelled", "failed", "expired":
+		return true
+	}
+	return false
+}
+
+// WaitForPayment polls the payment with ID paymentId until its status is
+// terminal or opts.Until returns true, and returns it. If ctx is done first,
+// it returns the payment as last polled and ctx's error. An error refreshing
+// the payment ends the wait.
+//
+// If client has a RefreshPaymentContext method, as *Account does, ctx also
+// cancels a poll in flight. Other clients are only checked between polls.
+func WaitForPayment(ctx context.Context, client Client, paymentId string, opts WaitOptions) (payment Payment, err error) {
+	if paymentId == "" {
+		err = errors.New("Cannot wait for a payment without an ID")
+		return
+	}
+
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = DefaultWaitInterval
+	}
+	maxInterval := opts.MaxInterval
+	if maxInterval <= 0 {
+		maxInterval = DefaultWaitMaxInterval
+	}
+	jitter := opts.Jitter
+	if jitter == 0 {
+		jitter = DefaultWaitJitter
+	}
+
+	payment.Id = paymentId
+	lastStatus := ""
+	for polls := 0; ; polls++ {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
+		if err = refreshPayment(ctx, client, &payment); err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			logger.Println("Could not refresh payment", paymentId, "while waiting:", err)
+			return
+		}
+		if polls > 0 && payment.Status != lastStatus && opts.OnStatusChange != nil {
+			opts.OnStatusChange(payment, lastStatus)
+		}
+		lastStatus = payment.Status
+
+		if IsTerminalStatus(payment.Status) || (opts.Until != nil && opts.Until(payment)) {
+			return
+		}
+
+		wait := interval
+		if jitter > 0 {
+			wait += time.Duration((rand.Float64()*2 - 1) * jitter * float64(interval))
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			err = ctx.Err()
+			return
+		case <-timer.C:
+		}
+
+		if interval *= 2; interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
+
+// WaitForPayment polls one of the account's payments until it is settled,
+// cancelled, failed or expired. See the package-level WaitForPayment.
+func (a *Account) WaitForPayment(ctx context.Context, paymentId string, opts WaitOptions) (Payment, error) {
+	return WaitForPayment(ctx, a, paymentId, opts)
+}
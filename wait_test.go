@@ -0,0 +1,137 @@
+package govenmo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// stubClient is a Client whose methods are set per test. Methods left unset
+// panic.
+type stubClient struct {
+	Client
+	refreshPayment  func(payment *Payment) error
+	completePayment func(paymentId, action string) (Payment, error)
+	paymentsSince   func(updatedSince time.Time) ([]Payment, error)
+	fetchFriends    func() ([]User, error)
+	payOrCharge     func(target Target, amount float64, note string, audience string) (Payment, error)
+}
+
+func (c *stubClient) RefreshPayment(payment *Payment) error {
+	return c.refreshPayment(payment)
+}
+
+func (c *stubClient) CompletePayment(paymentId, action string) (Payment, error) {
+	return c.completePayment(paymentId, action)
+}
+
+func (c *stubClient) PaymentsSince(updatedSince time.Time) ([]Payment, error) {
+	return c.paymentsSince(updatedSince)
+}
+
+func (c *stubClient) FetchFriends() ([]User, error) {
+	return c.fetchFriends()
+}
+
+func (c *stubClient) PayOrCharge(target Target, amount float64, note string, audience string) (Payment, error) {
+	return c.payOrCharge(target, amount, note, audience)
+}
+
+// statusSequence returns a RefreshPayment that reports each status in turn,
+// repeating the last one.
+func statusSequence(polls *int, statuses ...string) func(payment *Payment) error {
+	return func(payment *Payment) error {
+		i := *polls
+		if i >= len(statuses) {
+			i = len(statuses) - 1
+		}
+		*polls++
+		payment.Status = statuses[i]
+		return nil
+	}
+}
+
+func TestWaitForPayment(t *testing.T) {
+	polls := 0
+	client := &stubClient{refreshPayment: statusSequence(&polls, "pending", "pending", "settled")}
+
+	var changes []string
+	payment, err := WaitForPayment(context.Background(), client, "1", WaitOptions{
+		Interval: time.Millisecond,
+		OnStatusChange: func(payment Payment, from string) {
+			changes = append(changes, from+"->"+payment.Status)
+		},
+	})
+	if err != nil || payment.Status != "settled" || payment.Id != "1" {
+		t.Errorf("Wait should have returned the settled payment: %+v %v", payment, err)
+	}
+	if polls != 3 {
+		t.Error("Wait should have stopped polling once settled, polled", polls)
+	}
+	if len(changes) != 1 || changes[0] != "pending->settled" {
+		t.Error("Wait should have reported the status change:", changes)
+	}
+}
+
+func TestWaitForPaymentUntil(t *testing.T) {
+	polls := 0
+	client := &stubClient{refreshPayment: func(payment *Payment) error {
+		polls++
+		payment.Status = "pending"
+		if polls == 2 {
+			payment.Note = "Seen"
+		}
+		return nil
+	}}
+
+	payment, err := WaitForPayment(context.Background(), client, "1", WaitOptions{
+		Interval: time.Millisecond,
+		Until:    func(payment Payment) bool { return payment.Note == "Seen" },
+	})
+	if err != nil || polls != 2 || payment.Note != "Seen" {
+		t.Error("Wait should have stopped when Until returned true:", polls, err)
+	}
+}
+
+func TestWaitForPaymentContext(t *testing.T) {
+	polls := 0
+	client := &stubClient{refreshPayment: statusSequence(&polls, "pending")}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	payment, err := WaitForPayment(ctx, client, "1", WaitOptions{Interval: time.Millisecond, MaxInterval: 5 * time.Millisecond})
+	if err != context.DeadlineExceeded || payment.Status != "pending" {
+		t.Error("Wait should have stopped at the deadline with the last payment:", payment.Status, err)
+	}
+	if polls < 2 {
+		t.Error("Wait should have polled until the deadline, polled", polls)
+	}
+
+	failure := errors.New("Resource not found.")
+	client.refreshPayment = func(payment *Payment) error { return failure }
+	if _, err := WaitForPayment(context.Background(), client, "1", WaitOptions{}); err != failure {
+		t.Error("Wait should have returned the refresh error:", err)
+	}
+}
+
+func TestWaitForPaymentCancelsHungPoll(t *testing.T) {
+	t.Parallel()
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer hung.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err := testAccount(hung.URL).WaitForPayment(ctx, "1", WaitOptions{})
+	if err != context.DeadlineExceeded {
+		t.Error("Wait should have returned the deadline error:", err)
+	}
+	if waited := time.Since(started); waited > time.Second {
+		t.Error("Wait should have given up on the hung poll at the deadline, waited", waited)
+	}
+}
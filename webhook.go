@@ -0,0 +1,225 @@
+package govenmo
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultWebhookDedupeWindow is how long a WebhookHandler remembers deliveries.
+const DefaultWebhookDedupeWindow = 24 * time.Hour
+
+// maxWebhookBody caps how much of a webhook POST is read.
+const maxWebhookBody = 1 << 20
+
+// WebhookHandler is an http.Handler that receives Venmo webhook callbacks.
+// It answers Venmo's verification challenge, checks the shared secret,
+// decodes the payment, drops redeliveries and publishes an Event for it.
+//
+//	handler := govenmo.NewWebhookHandler(bus)
+//	handler.Secret = "..."
+//	http.Handle("/venmo/webhook", handler)
+type WebhookHandler struct {
+	// Secret, if set, must match the "secret" query parameter of every POST.
+	// Include it in the callback URL registered with Venmo.
+	Secret string
+
+	// Events receives an Event for every new delivery.
+	Events *EventBus
+
+	// Store, if set, is used to work out what changed in payment.updated
+	// deliveries and is updated with every payment received.
+	Store PaymentStore
+
+	// DedupeWindow is how long a delivery is remembered so that a redelivery
+	// of the same body is acknowledged without publishing again. Zero or
+	// less uses DefaultWebhookDedupeWindow.
+	DedupeWindow time.Duration
+
+	mu   sync.Mutex
+	seen map[[sha256.Size]byte]time.Time
+	now  func() time.Time
+}
+
+// NewWebhookHandler creates a WebhookHandler publishing to events.
+func NewWebhookHandler(events *EventBus) *WebhookHandler {
+	return &WebhookHandler{
+		Events:       events,
+		DedupeWindow: DefaultWebhookDedupeWindow,
+	}
+}
+
+// WebhookPayload is the body of a Venmo webhook POST.
+type WebhookPayload struct {
+	DateCreated *Time   `json:"date_created"`
+	Type        string  `json:"type"`
+	Data        Payment `json:"data"`
+}
+
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		h.serveChallenge(w, r)
+	case "POST":
+		h.serveDelivery(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// serveChallenge echoes venmo_challenge, which is how Venmo verifies a
+// callback URL when it is registered.
+func (h *WebhookHandler) serveChallenge(w http.ResponseWriter, r *http.Request) {
+	challenge := r.URL.Query().Get("venmo_challenge")
+	if challenge == "" {
+		http.Error(w, "Missing venmo_challenge", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	io.WriteString(w, challenge)
+}
+
+func (h *WebhookHandler) serveDelivery(w http.ResponseWriter, r *http.Request) {
+	if h.Secret != "" && subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("secret")), []byte(h.Secret)) != 1 {
+		logger.Println("Rejecting webhook with wrong secret")
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		logger.Println("Could not read webhook body:", err)
+		http.Error(w, "Could not read body", http.StatusBadRequest)
+		return
+	}
+
+	logger.Println("Received webhook:", string(body))
+
+	key := sha256.Sum256(body)
+	if !h.claim(key) {
+		logger.Println("Ignoring webhook redelivery")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var payload WebhookPayload
+	err = json.Unmarshal(body, &payload)
+	if err != nil {
+		logger.Println("Could not parse webhook:", err)
+		h.release(key)
+		http.Error(w, "Could not parse body", http.StatusBadRequest)
+		return
+	}
+
+	var events []Event
+	switch payload.Type {
+	case "payment.created", "payment.updated":
+		if payload.Data.Id == "" {
+			h.release(key)
+			http.Error(w, "Payment has no ID", http.StatusBadRequest)
+			return
+		}
+		events, err = h.paymentEvents(payload)
+		if err != nil {
+			logger.Println("Could not store webhook payment:", err)
+			// Venmo retries on errors, so the delivery is forgotten.
+			h.release(key)
+			http.Error(w, "Could not store payment", http.StatusInternalServerError)
+			return
+		}
+	default:
+		logger.Println("Ignoring webhook of unknown type:", payload.Type)
+	}
+
+	if h.Events != nil {
+		h.Events.Publish(events...)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *WebhookHandler) paymentEvents(payload WebhookPayload) ([]Event, error) {
+	payment := payload.Data
+
+	if h.Store == nil {
+		if payload.Type == "payment.created" {
+			return []Event{PaymentCreated{Payment: payment}}, nil
+		}
+		return []Event{PaymentUpdated{Payment: payment}}, nil
+	}
+
+	var previous *Payment
+	stored, err := h.Store.GetPayment(payment.Id)
+	switch {
+	case err == nil:
+		if staleDelivery(stored, payment) {
+			logger.Println("Ignoring out of date webhook for payment", payment.Id)
+			return nil, nil
+		}
+		previous = &stored
+	case err != ErrNotFound:
+		return nil, err
+	}
+
+	err = h.Store.UpsertPayment(payment)
+	if err != nil {
+		return nil, err
+	}
+	return PaymentEvents("", previous, payment), nil
+}
+
+// staleDelivery reports whether a delivered payment is older than the stored
+// copy, which happens when Venmo's retries arrive out of order. A completed
+// payment never goes back to pending, and an earlier completion loses.
+func staleDelivery(stored, delivered Payment) bool {
+	if IsTerminalStatus(stored.Status) && delivered.Status == "pending" {
+		return true
+	}
+	return stored.DateCompleted != nil && delivered.DateCompleted != nil && delivered.DateCompleted.Before(stored.DateCompleted.Time)
+}
+
+func (h *WebhookHandler) clock() time.Time {
+	if h.now != nil {
+		return h.now()
+	}
+	return time.Now()
+}
+
+// claim marks a delivery as seen, unless it already was within the dedupe
+// window, in which case it returns false. Checking and marking in one step
+// means only one of two identical deliveries arriving together is handled.
+func (h *WebhookHandler) claim(key [sha256.Size]byte) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.clock()
+	window := h.DedupeWindow
+	if window <= 0 {
+		window = DefaultWebhookDedupeWindow
+	}
+	if h.seen == nil {
+		h.seen = map[[sha256.Size]byte]time.Time{}
+	}
+	for seenKey, at := range h.seen {
+		if now.Sub(at) >= window {
+			delete(h.seen, seenKey)
+		}
+	}
+	if _, ok := h.seen[key]; ok {
+		return false
+	}
+	h.seen[key] = now
+	return true
+}
+
+// release forgets a claimed delivery that could not be handled, so that
+// Venmo's retry is.
+func (h *WebhookHandler) release(key [sha256.Size]byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.seen, key)
+}
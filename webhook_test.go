@@ -0,0 +1,260 @@
+package govenmo
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deet/govenmo/venmotest"
+)
+
+func deliverWebhook(t *testing.T, handler http.Handler, url, fixture string) *httptest.ResponseRecorder {
+	body, err := ioutil.ReadFile("testdata/webhooks/" + fixture)
+	if err != nil {
+		t.Fatal("Could not read fixture:", err)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("POST", url, bytes.NewReader(body)))
+	return recorder
+}
+
+func TestWebhookChallenge(t *testing.T) {
+	handler := NewWebhookHandler(nil)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/webhook?venmo_challenge=abc123", nil))
+	if recorder.Code != 200 || recorder.Body.String() != "abc123" {
+		t.Error("Challenge should have been echoed:", recorder.Code, recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/webhook", nil))
+	if recorder.Code != 400 {
+		t.Error("Missing challenge should be a bad request:", recorder.Code)
+	}
+}
+
+func TestWebhookSecret(t *testing.T) {
+	bus := NewEventBus()
+	var received []Event
+	bus.Subscribe(func(event Event) { received = append(received, event) })
+
+	handler := NewWebhookHandler(bus)
+	handler.Secret = "s3cret"
+
+	if recorder := deliverWebhook(t, handler, "/webhook?secret=wrong", "payment_created.json"); recorder.Code != 403 {
+		t.Error("Wrong secret should be forbidden:", recorder.Code)
+	}
+	if recorder := deliverWebhook(t, handler, "/webhook", "payment_created.json"); recorder.Code != 403 {
+		t.Error("Missing secret should be forbidden:", recorder.Code)
+	}
+	if recorder := deliverWebhook(t, handler, "/webhook?secret=s3cret", "payment_created.json"); recorder.Code != 200 {
+		t.Error("Right secret should be accepted:", recorder.Code)
+	}
+	if len(received) != 1 {
+		t.Error("Only the authenticated delivery should publish:", received)
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	bus := NewEventBus()
+	var received []Event
+	bus.Subscribe(func(event Event) { received = append(received, event) })
+
+	handler := NewWebhookHandler(bus)
+	deliverWebhook(t, handler, "/webhook", "payment_created.json")
+	deliverWebhook(t, handler, "/webhook", "payment_created.json")
+	deliverWebhook(t, handler, "/webhook", "payment_updated.json")
+
+	if len(received) != 2 {
+		t.Fatalf("Redelivery should have been dropped: %+v", received)
+	}
+	created, ok := received[0].(PaymentCreated)
+	if !ok || created.Payment.Id != "1322585332520059420" || created.Payment.Actor.Username != "someone-else" || created.Payment.DateCreated.Nanosecond() != 865985000 {
+		t.Errorf("Expected a decoded PaymentCreated: %+v", received[0])
+	}
+	updated, ok := received[1].(PaymentUpdated)
+	if !ok || updated.Payment.Status != "settled" || updated.Payment.DateCompleted == nil {
+		t.Errorf("Expected a PaymentUpdated: %+v", received[1])
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/webhook", bytes.NewBufferString("{not json")))
+	if recorder.Code != 400 {
+		t.Error("Malformed body should be a bad request:", recorder.Code)
+	}
+}
+
+func TestWebhookLiteralHandlerDedupes(t *testing.T) {
+	bus := NewEventBus()
+	var received []Event
+	bus.Subscribe(func(event Event) { received = append(received, event) })
+
+	handler := &WebhookHandler{Events: bus}
+	deliverWebhook(t, handler, "/webhook", "payment_created.json")
+	deliverWebhook(t, handler, "/webhook", "payment_created.json")
+	if len(received) != 1 {
+		t.Errorf("Handler without a DedupeWindow should use the default: %+v", received)
+	}
+}
+
+func TestWebhookWithStore(t *testing.T) {
+	bus := NewEventBus()
+	var received []Event
+	bus.Subscribe(func(event Event) { received = append(received, event) })
+
+	store := NewMemoryStore()
+	handler := NewWebhookHandler(bus)
+	handler.Store = store
+
+	deliverWebhook(t, handler, "/webhook", "payment_created.json")
+	deliverWebhook(t, handler, "/webhook", "payment_updated.json")
+
+	if len(received) != 2 {
+		t.Fatalf("Expected two events: %+v", received)
+	}
+	changed, ok := received[1].(PaymentStatusChanged)
+	if !ok || changed.From != "pending" || changed.To != "settled" {
+		t.Errorf("Expected a PaymentStatusChanged: %+v", received[1])
+	}
+
+	stored, err := store.GetPayment("1322585332520059420")
+	if err != nil || stored.Status != "settled" {
+		t.Error("Store should have the settled payment:", stored.Status, err)
+	}
+}
+
+func TestWebhookOutOfOrder(t *testing.T) {
+	bus := NewEventBus()
+	var received []Event
+	bus.Subscribe(func(event Event) { received = append(received, event) })
+
+	store := NewMemoryStore()
+	handler := NewWebhookHandler(bus)
+	handler.Store = store
+
+	deliverWebhook(t, handler, "/webhook", "payment_updated.json")
+	if recorder := deliverWebhook(t, handler, "/webhook", "payment_created.json"); recorder.Code != 200 {
+		t.Error("Late delivery should still be acknowledged:", recorder.Code)
+	}
+
+	if len(received) != 1 {
+		t.Errorf("Late payment.created should not publish: %+v", received)
+	}
+	stored, err := store.GetPayment("1322585332520059420")
+	if err != nil || stored.Status != "settled" {
+		t.Error("Store should keep the settled payment:", stored.Status, err)
+	}
+
+	earlier := stored
+	earlier.Status = "cancelled"
+	earlier.DateCompleted = &Time{Time: stored.DateCompleted.Add(-time.Minute)}
+	if !staleDelivery(stored, earlier) {
+		t.Error("Earlier completion should be stale")
+	}
+}
+
+// gatedStore is a MemoryStore whose UpsertPayment waits for gate and can be
+// made to fail.
+type gatedStore struct {
+	*MemoryStore
+	gate chan struct{}
+	fail bool
+}
+
+func (s *gatedStore) UpsertPayment(payment Payment) error {
+	<-s.gate
+	if s.fail {
+		return errors.New("Database is down")
+	}
+	return s.MemoryStore.UpsertPayment(payment)
+}
+
+func TestWebhookConcurrentRedelivery(t *testing.T) {
+	bus := NewEventBus()
+	var mu sync.Mutex
+	var received []Event
+	bus.Subscribe(func(event Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, event)
+	})
+
+	store := &gatedStore{MemoryStore: NewMemoryStore(), gate: make(chan struct{}), fail: true}
+	handler := NewWebhookHandler(bus)
+	handler.Store = store
+
+	first := make(chan int)
+	go func() { first <- deliverWebhook(t, handler, "/webhook", "payment_created.json").Code }()
+	for {
+		// Wait until the first delivery holds its claim.
+		handler.mu.Lock()
+		claimed := len(handler.seen) == 1
+		handler.mu.Unlock()
+		if claimed {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if recorder := deliverWebhook(t, handler, "/webhook", "payment_created.json"); recorder.Code != 200 {
+		t.Error("Concurrent redelivery should be acknowledged:", recorder.Code)
+	}
+	close(store.gate)
+	if code := <-first; code != 500 {
+		t.Error("First delivery should have failed to store:", code)
+	}
+
+	store.fail = false
+	if recorder := deliverWebhook(t, handler, "/webhook", "payment_created.json"); recorder.Code != 200 {
+		t.Error("Retry after a failure should be handled:", recorder.Code)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 {
+		t.Errorf("Only the successful retry should publish: %+v", received)
+	}
+}
+
+func TestWebhooksFromSandbox(t *testing.T) {
+	t.Parallel()
+	bus := NewEventBus()
+	events, unsubscribe := bus.SubscribeChan(10)
+	defer unsubscribe()
+
+	handler := NewWebhookHandler(bus)
+	handler.Secret = "s3cret"
+	handler.Store = NewMemoryStore()
+	receiver := httptest.NewServer(handler)
+	defer receiver.Close()
+
+	server := venmotest.NewServer(venmotest.Stateful(), venmotest.WithWebhooks(receiver.URL+"/webhook?secret=s3cret"), venmotest.WithSettlementDelay(10*time.Millisecond))
+	defer server.Close()
+
+	account := testAccount(server.URL)
+	sent, err := account.PayOrCharge(Target{User: User{Id: venmotest.SandboxUserId}}, 2.50, "Coffee", "private")
+	if err != nil {
+		t.Fatal("Payment should not have errored:", err)
+	}
+	server.Sandbox.WaitForWebhooks()
+
+	var received []Event
+	for len(received) < 2 {
+		select {
+		case event := <-events:
+			received = append(received, event)
+		case <-time.After(time.Second):
+			t.Fatalf("Expected created and settled events: %+v", received)
+		}
+	}
+	if _, ok := received[0].(PaymentCreated); !ok || received[0].EventPayment().Id != sent.Id {
+		t.Errorf("Expected a PaymentCreated: %+v", received[0])
+	}
+	if changed, ok := received[1].(PaymentStatusChanged); !ok || changed.From != "pending" || changed.To != "settled" {
+		t.Errorf("Expected a PaymentStatusChanged: %+v", received[1])
+	}
+}